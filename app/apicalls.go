@@ -0,0 +1,166 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APICall is a request made to the Eliona API through the harness.
+type APICall struct {
+	Time   time.Time
+	Method string
+	Path   string
+	Query  url.Values
+	Body   []byte
+	// Status is the status code of the response, or 0 if no response was sent.
+	Status int
+}
+
+// Matches reports whether the call has the given method and a path matching
+// the pattern. The pattern is matched as by path.Match, so "*" matches a single
+// path segment, e.g. "/v2/asset-types/*/attributes".
+func (c APICall) Matches(method, pattern string) bool {
+	if !strings.EqualFold(c.Method, method) {
+		return false
+	}
+	matched, err := path.Match(pattern, c.Path)
+	return err == nil && matched
+}
+
+func (c APICall) String() string {
+	s := fmt.Sprintf("%s %s", c.Method, c.Path)
+	if len(c.Query) > 0 {
+		s += "?" + c.Query.Encode()
+	}
+	return fmt.Sprintf("%s -> %d", s, c.Status)
+}
+
+// APICallLog keeps all calls to the Eliona API in the order they were made.
+// It is safe for concurrent use.
+type APICallLog struct {
+	mu    sync.Mutex
+	calls []APICall
+}
+
+func (l *APICallLog) append(call APICall) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+// Calls returns a copy of all calls in the log.
+func (l *APICallLog) Calls() []APICall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]APICall(nil), l.calls...)
+}
+
+// Matching returns all calls with the given method and a path matching the
+// pattern, see APICall.Matches.
+func (l *APICallLog) Matching(method, pattern string) []APICall {
+	var matching []APICall
+	for _, call := range l.Calls() {
+		if call.Matches(method, pattern) {
+			matching = append(matching, call)
+		}
+	}
+	return matching
+}
+
+// Reset removes all calls from the log, e.g. to check only the calls caused
+// by a single test.
+func (l *APICallLog) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = nil
+}
+
+// APICalls returns the calls to the Eliona API made through the harness started
+// by RunApp or StartApp.
+func APICalls() *APICallLog {
+	if defaultHarness == nil {
+		return &APICallLog{}
+	}
+	return defaultHarness.APICalls()
+}
+
+// APICalls returns the calls to the Eliona API made through the harness since
+// it was created. Calls are only recorded with WithMock or WithAPIRecording.
+func (h *Harness) APICalls() *APICallLog {
+	return h.apiCalls
+}
+
+// recordAPICalls records every request passed to next.
+func (h *Harness) recordAPICalls(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := APICall{
+			Time:   time.Now(),
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+		}
+		if r.Body != nil {
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("reading request body: %v", err), http.StatusBadRequest)
+				return
+			}
+			call.Body = body
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			call.Status = recorder.status
+			h.apiCalls.append(call)
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// statusRecorder remembers the status code written to the response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
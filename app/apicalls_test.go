@@ -0,0 +1,112 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAPICalls(t *testing.T) {
+	h := &Harness{apiCalls: &APICallLog{}}
+	server := httptest.NewServer(h.recordAPICalls(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler still gets the body read by the recorder.
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/v2/assets":
+			_, _ = w.Write(body)
+		case "/v2/asset-types/my_room/attributes":
+			w.WriteHeader(http.StatusNoContent)
+		case "/v2/abort":
+			panic(http.ErrAbortHandler)
+		default:
+			http.NotFound(w, r)
+		}
+	})))
+	defer server.Close()
+
+	send := func(method, target, body string) {
+		request, err := http.NewRequest(method, server.URL+target, strings.NewReader(body))
+		require.NoError(t, err)
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return
+		}
+		defer response.Body.Close()
+		if method == http.MethodPut && target == "/v2/assets" {
+			echoed, _ := io.ReadAll(response.Body)
+			assert.Equal(t, body, string(echoed))
+		}
+	}
+	send(http.MethodPut, "/v2/assets", `{"globalAssetIdentifier": "room-1"}`)
+	send(http.MethodPut, "/v2/asset-types/my_room/attributes?expansions=a,b", `{"name": "temperature"}`)
+	send(http.MethodGet, "/v2/unknown?assetId=7&assetId=8", "")
+	// Unlike GET, the client does not retry an aborted POST.
+	send(http.MethodPost, "/v2/abort", "{}")
+
+	calls := h.APICalls().Calls()
+	require.Len(t, calls, 4)
+	want := []APICall{
+		{Method: http.MethodPut, Path: "/v2/assets", Query: url.Values{}, Body: []byte(`{"globalAssetIdentifier": "room-1"}`), Status: http.StatusOK},
+		{Method: http.MethodPut, Path: "/v2/asset-types/my_room/attributes", Query: url.Values{"expansions": {"a,b"}}, Body: []byte(`{"name": "temperature"}`), Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/v2/unknown", Query: url.Values{"assetId": {"7", "8"}}, Body: []byte{}, Status: http.StatusNotFound},
+		{Method: http.MethodPost, Path: "/v2/abort", Query: url.Values{}, Body: []byte("{}"), Status: 0},
+	}
+	for i, call := range calls {
+		assert.False(t, call.Time.IsZero())
+		call.Time = want[i].Time
+		assert.Equal(t, want[i], call)
+	}
+
+	assert.Equal(t, "PUT /v2/asset-types/my_room/attributes?expansions=a%2Cb -> 204", calls[1].String())
+	assert.Equal(t, calls[1:2], h.APICalls().Matching("put", "/v2/asset-types/*/attributes"))
+	assert.Empty(t, h.APICalls().Matching(http.MethodGet, "/v2/asset-types/*/attributes"))
+	h.APICalls().Reset()
+	assert.Empty(t, h.APICalls().Calls())
+}
+
+func TestStatusRecorder(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w http.ResponseWriter)
+		want  int
+	}{
+		{"nothing written", func(w http.ResponseWriter) {}, 0},
+		{"implicit ok", func(w http.ResponseWriter) { _, _ = w.Write([]byte("ok")) }, http.StatusOK},
+		{"explicit status", func(w http.ResponseWriter) { w.WriteHeader(http.StatusCreated) }, http.StatusCreated},
+		{"first status wins", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("error"))
+		}, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := httptest.NewRecorder()
+			recorder := &statusRecorder{ResponseWriter: response}
+			tt.write(recorder)
+			assert.Equal(t, tt.want, recorder.status)
+			assert.Same(t, response, recorder.Unwrap())
+		})
+	}
+}
@@ -0,0 +1,145 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/mock"
+	"github.com/eliona-smart-building-assistant/go-utils/db"
+)
+
+// startAPI serves the Eliona API used by the app, either the built-in mock or
+// a proxy to the Eliona API given by API_ENDPOINT, and points API_ENDPOINT to
+// it, both for the app and for the tests. Apps running in a container reach it
+// via host.docker.internal, so it has to listen on all interfaces then.
+func (h *Harness) startAPI() error {
+	if !h.servesAPI() {
+		return nil
+	}
+	h.apiEndpoint, h.apiEndpointSet = os.LookupEnv("API_ENDPOINT")
+
+	var handler http.Handler
+	if h.mock {
+		h.mockDB = db.NewDatabase("app-integration-mock")
+		handler = mock.New(h.mockDB, os.Getenv("API_TOKEN")).Handler()
+		h.apiBasePath = mock.BasePath
+	} else {
+		upstream, err := url.Parse(h.apiEndpoint)
+		if err != nil || upstream.Host == "" {
+			return fmt.Errorf("parsing API_ENDPOINT %q as URL", h.apiEndpoint)
+		}
+		handler = apiReverseProxy(upstream)
+		h.apiBasePath = upstream.Path
+	}
+
+	addr := "localhost:0"
+	if h.RunsInContainer() {
+		addr = ":0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening for Eliona API: %w", err)
+	}
+	if h.faults {
+		handler = h.injectAPIFaults(handler)
+	}
+	h.apiServer = &http.Server{Handler: h.recordAPICalls(handler), ConnState: h.apiFaults.connState}
+	h.apiPort = listener.Addr().(*net.TCPAddr).Port
+	go func() {
+		_ = h.apiServer.Serve(listener)
+	}()
+
+	endpoint := fmt.Sprintf("http://localhost:%d%s", h.apiPort, h.apiBasePath)
+	if err := os.Setenv("API_ENDPOINT", endpoint); err != nil {
+		return fmt.Errorf("setting API_ENDPOINT: %w", err)
+	}
+	if h.mock {
+		fmt.Printf("Serving the Eliona API mock on %s\n", endpoint)
+	} else {
+		fmt.Printf("Proxying the Eliona API %s on %s\n", h.apiEndpoint, endpoint)
+	}
+	return nil
+}
+
+// stopAPI stops serving the Eliona API and restores API_ENDPOINT.
+func (h *Harness) stopAPI(ctx context.Context) error {
+	if !h.servesAPI() {
+		return nil
+	}
+	var err error
+	if h.apiServer != nil {
+		err = h.apiServer.Shutdown(ctx)
+		h.apiServer = nil
+	}
+	if h.mockDB != nil {
+		err = errors.Join(err, h.mockDB.Close())
+		h.mockDB = nil
+	}
+
+	if h.apiEndpointSet {
+		err = errors.Join(err, os.Setenv("API_ENDPOINT", h.apiEndpoint))
+	} else {
+		err = errors.Join(err, os.Unsetenv("API_ENDPOINT"))
+	}
+	return err
+}
+
+// apiReverseProxy forwards requests to the Eliona API.
+func apiReverseProxy(upstream *url.URL) http.Handler {
+	target := *upstream
+	if port := target.Port(); port != "" {
+		target.Host = net.JoinHostPort(localHostname(target.Hostname()), port)
+	} else {
+		target.Host = localHostname(target.Hostname())
+	}
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.Out.URL.Scheme = target.Scheme
+			r.Out.URL.Host = target.Host
+			r.Out.Host = target.Host
+		},
+	}
+}
+
+// servesAPI reports whether the harness serves the Eliona API used by the app.
+func (h *Harness) servesAPI() bool {
+	return h.mock || h.recordAPI || h.faults
+}
+
+// containerAPIEndpoint returns the Eliona API endpoint as seen from a container.
+func (h *Harness) containerAPIEndpoint() string {
+	if h.apiServer == nil {
+		return os.Getenv("API_ENDPOINT")
+	}
+	return fmt.Sprintf("http://host.docker.internal:%d%s", h.apiPort, h.apiBasePath)
+}
+
+// RunsInContainer reports whether the app is started in a container.
+func (h *Harness) RunsInContainer() bool {
+	switch h.mode {
+	case StartModeDocker, StartModeImage, StartModeCompose:
+		return true
+	}
+	return false
+}
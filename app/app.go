@@ -17,12 +17,16 @@ package app
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -31,41 +35,128 @@ import (
 )
 
 var (
-	appLocation string
+	appLocation   string
+	mode          string
+	hostPort      int
+	containerPort int
+	readyTimeout  time.Duration
+	stopTimeout   time.Duration
+	shutdownTime  time.Duration
+	allowlist     string
+	logFormat     string
+	logLevelKey   string
+	image         string
+	race          bool
+	cover         bool
+	coverProfile  string
+	composeFile   string
+	composeSvc    string
+	command       string
+	commandDir    string
+	commandEnv    envFlag
+	mockAPI       bool
+	recordAPI     bool
+	faults        bool
+	bootstrap     bool
+	snapshot      bool
+	restrictRole  bool
+
+	flagsOnce sync.Once
 )
 
+// RunApp starts the app, runs the tests and stops the app again. The app is
+// stopped even if starting it or any of the tests fails. The process exits
+// with the code of the tests, or 1 if starting or stopping the app failed.
+//
+// Errors logged by the app are not attributed to tests automatically. They
+// only fail the test running at the time if that test called Watch, as
+// test.AppWorks does, and no other watched test runs in parallel. All other
+// errors fail the run at its end, without naming a test.
 func RunApp(m *testing.M) {
-	StartApp()
-	m.Run()
-	StopApp()
+	os.Exit(runApp(m))
 }
 
-func StartApp() {
-	handleEnvironment()
-	resetDB()
-	switch StartMode() {
-	case StartModeDirect:
-		startAppDirectly()
-	case StartModeDocker:
-		startAppContainer()
+func runApp(m *testing.M) int {
+	if err := StartApp(); err != nil {
+		fmt.Printf("starting app: %v\n", err)
+		if err := closeApp(); err != nil {
+			fmt.Printf("stopping app: %v\n", err)
+		}
+		return 1
+	}
+
+	code := m.Run()
+
+	if err := closeApp(); err != nil {
+		fmt.Printf("stopping app: %v\n", err)
+		if code == 0 {
+			code = 1
+		}
 	}
+	return code
 }
 
-func StopApp() {
-	switch StartMode() {
-	case StartModeDirect:
-		stopAppDirectly()
-	case StartModeDocker:
-		stopAppContainer()
+// StartApp starts the app configured by command line flags and environment
+// variables. The started app is available via Default.
+func StartApp() error {
+	if defaultHarness == nil {
+		handleFlags()
+		options, err := optionsFromFlags()
+		if err != nil {
+			return err
+		}
+		h, err := NewHarness(options...)
+		if err != nil {
+			return err
+		}
+		defaultHarness = h
 	}
+	return defaultHarness.Start(context.Background())
+}
+
+// StopApp stops the app started by StartApp.
+func StopApp() error {
+	if defaultHarness == nil {
+		return nil
+	}
+	return defaultHarness.Stop(context.Background())
+}
+
+// closeApp stops the app started by StartApp and removes its temporary files.
+func closeApp() error {
+	if defaultHarness == nil {
+		return nil
+	}
+	return defaultHarness.Close(context.Background())
 }
 
 const (
-	StartModeDirect string = "direct"
-	StartModeDocker string = "docker"
+	StartModeDirect  string = "direct"
+	StartModeDocker  string = "docker"
+	StartModeImage   string = "image"
+	StartModeBinary  string = "binary"
+	StartModeCompose string = "compose"
+	StartModeCommand string = "command"
 )
 
+// StartMode returns the mode the app is started in.
 func StartMode() string {
+	if defaultHarness != nil {
+		return defaultHarness.mode
+	}
+	return startModeFromEnv()
+}
+
+// RunsInContainer reports whether the app is started in a container, with the
+// build information provided by its Dockerfile.
+func RunsInContainer() bool {
+	if defaultHarness != nil {
+		return defaultHarness.RunsInContainer()
+	}
+	return (&Harness{mode: startModeFromEnv()}).RunsInContainer()
+}
+
+func startModeFromEnv() string {
 	mode, present := os.LookupEnv("START_MODE")
 	if present {
 		return strings.ToLower(mode)
@@ -73,39 +164,157 @@ func StartMode() string {
 	return StartModeDocker
 }
 
-func handleFlags() {
-	flag.StringVar(&appLocation, "app", "", "Path to app")
-	flag.Parse()
-
-	if appLocation == "" {
-		appLocation = "."
+// BaseURL returns the URL under which the API of the running app is reachable
+// from the test process, e.g. "http://localhost:41234".
+func BaseURL() string {
+	if defaultHarness == nil {
+		return ""
 	}
+	return defaultHarness.BaseURL()
 }
 
-func handleEnvironment() {
-	if err := checkEnvVars(); err != nil {
-		fmt.Printf("checking environment variables: %v", err)
-		os.Exit(1)
-	}
+// handleFlags registers the flags of the harness on the global flag set. They
+// start with "app", so that they do not clash with flags of the app's tests.
+// Only -image, given when testing prebuilt images, is accepted without prefix.
+func handleFlags() {
+	flagsOnce.Do(func() {
+		flag.StringVar(&appLocation, "app", "", "Path to app")
+		flag.StringVar(&mode, "app-mode", "", "Start mode of the app (default: $START_MODE or docker)")
+		flag.StringVar(&image, "image", "", "Prebuilt image to run, implies -app-mode=image (default: $APP_IMAGE)")
+		flag.StringVar(&image, "app-image", "", "Same as -image")
+		flag.IntVar(&hostPort, "app-port", 0, "Host port for the app API (default: $APP_PORT or a free port)")
+		flag.IntVar(&containerPort, "app-container-port", 0, "Port the app API listens on inside the container (default: $APP_CONTAINER_PORT or 3000)")
+		flag.DurationVar(&readyTimeout, "app-ready-timeout", 0, "Time the app has to get ready (default: 1m)")
+		flag.DurationVar(&stopTimeout, "app-stop-timeout", 0, "Time the app has to stop before it is killed (default: 10s)")
+		flag.DurationVar(&shutdownTime, "app-shutdown-budget", 0, "Time a graceful shutdown may take (default: 5s)")
+		flag.StringVar(&allowlist, "app-error-allowlist", "", "File with patterns of allowed error log lines (default: error-allowlist.txt in the app directory)")
+		flag.StringVar(&logFormat, "app-log-format", "", "Format of the app log: auto, text, json or logfmt (default: auto)")
+		flag.StringVar(&logLevelKey, "app-log-level-key", "", "Key of the level in json and logfmt logs (default: level)")
+		flag.BoolVar(&race, "app-race", false, "Build the app with the race detector in binary mode")
+		flag.BoolVar(&cover, "app-cover", false, "Build the app with coverage instrumentation in binary mode")
+		flag.StringVar(&coverProfile, "app-coverprofile", "", "File to write the coverage profile of the app to, implies -app-cover")
+		flag.StringVar(&composeFile, "app-compose-file", "", "Compose file to bring up in compose mode (default: compose file in the app directory)")
+		flag.StringVar(&composeSvc, "app-compose-service", "", "Service running the app in compose mode (default: the service built from the app directory)")
+		flag.StringVar(&command, "app-command", "", "Command starting the app in command mode (default: $START_COMMAND)")
+		flag.StringVar(&commandDir, "app-workdir", "", "Working directory of the command, relative to the app directory (default: $START_WORKDIR)")
+		flag.Var(&commandEnv, "app-env", "Extra KEY=VALUE environment variable for the command, can be repeated")
+		flag.BoolVar(&mockAPI, "app-mock", false, "Serve the built-in Eliona API mock and point API_ENDPOINT to it")
+		flag.BoolVar(&recordAPI, "app-record-api", false, "Record the calls of the app to the Eliona API through a proxy")
+		flag.BoolVar(&bootstrap, "app-bootstrap-db", false, "Create a minimal Eliona core schema in the database if it does not exist")
+		flag.BoolVar(&snapshot, "app-snapshot", false, "Snapshot the database once the app is initialized, for RestoreSnapshot")
+		flag.BoolVar(&restrictRole, "app-restricted-role", false, "Start the app with a database role restricted to the app schema and the documented public tables")
+		flag.BoolVar(&faults, "app-faults", false, "Put proxies injecting faults between the app and the Eliona API and database")
+		flag.Parse()
+	})
+}
 
-	handleFlags()
-	if err := os.Chdir(appLocation); err != nil {
-		fmt.Printf("chdir to %s: %v", appLocation, err)
-		os.Exit(1)
+// optionsFromFlags returns the options set on the command line. Options not
+// set on the command line are left to the defaults of NewHarness.
+func optionsFromFlags() ([]Option, error) {
+	var options []Option
+	if appLocation != "" {
+		options = append(options, WithAppLocation(appLocation))
+	}
+	if mode != "" {
+		options = append(options, WithMode(mode))
+	}
+	if image != "" {
+		options = append(options, WithImage(image))
+		if mode == "" {
+			options = append(options, WithMode(StartModeImage))
+		}
+	}
+	if hostPort != 0 {
+		options = append(options, WithHostPort(hostPort))
+	}
+	if containerPort != 0 {
+		options = append(options, WithContainerPort(containerPort))
+	}
+	if readyTimeout != 0 {
+		options = append(options, WithReadyTimeout(readyTimeout))
+	}
+	if stopTimeout != 0 {
+		options = append(options, WithStopTimeout(stopTimeout))
+	}
+	if shutdownTime != 0 {
+		options = append(options, WithShutdownBudget(shutdownTime))
+	}
+	if allowlist != "" {
+		options = append(options, WithErrorAllowlistFile(allowlist))
+	}
+	if race {
+		options = append(options, WithRace(true))
+	}
+	if cover || coverProfile != "" {
+		options = append(options, WithCover(true))
 	}
+	if coverProfile != "" {
+		options = append(options, WithCoverProfile(coverProfile))
+	}
+	if composeFile != "" {
+		options = append(options, WithComposeFile(composeFile))
+	}
+	if composeSvc != "" {
+		options = append(options, WithComposeService(composeSvc))
+	}
+	if command != "" {
+		args, err := splitCommand(command)
+		if err != nil {
+			return nil, err
+		}
+		if len(args) > 0 {
+			options = append(options, WithCommand(args[0], args[1:]...))
+		}
+	}
+	if commandDir != "" {
+		options = append(options, WithCommandDir(commandDir))
+	}
+	if len(commandEnv) > 0 {
+		options = append(options, WithCommandEnv(commandEnv...))
+	}
+	if mockAPI {
+		options = append(options, WithMock(true))
+	}
+	if recordAPI {
+		options = append(options, WithAPIRecording(true))
+	}
+	if faults {
+		options = append(options, WithFaultInjection(true))
+	}
+	if bootstrap {
+		options = append(options, WithDBBootstrap(true))
+	}
+	if snapshot {
+		options = append(options, WithSnapshot(true))
+	}
+	if restrictRole {
+		options = append(options, WithRestrictedRole(true))
+	}
+	if logFormat != "" || logLevelKey != "" {
+		parser, err := NewLogParser(logFormat, logLevelKey)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithLogParser(parser))
+	}
+	return options, nil
 }
 
-func checkEnvVars() error {
+// checkEnvVars checks the environment variables needed by the app. With the
+// mock, API_ENDPOINT is set by the harness and API_TOKEN is optional.
+func checkEnvVars(withMock bool) error {
 	var present bool
 
-	_, present = os.LookupEnv("API_ENDPOINT")
-	if !present {
-		return errors.New("API_ENDPOINT variable not defined")
-	}
+	if !withMock {
+		_, present = os.LookupEnv("API_ENDPOINT")
+		if !present {
+			return errors.New("API_ENDPOINT variable not defined")
+		}
 
-	_, present = os.LookupEnv("API_TOKEN")
-	if !present {
-		return errors.New("API_TOKEN variable not defined")
+		_, present = os.LookupEnv("API_TOKEN")
+		if !present {
+			return errors.New("API_TOKEN variable not defined")
+		}
 	}
 
 	_, present = os.LookupEnv("CONNECTION_STRING")
@@ -115,18 +324,34 @@ func checkEnvVars() error {
 	return nil
 }
 
-func waitForAppReady() error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-	defer cancel()
-
-	fmt.Println("Waiting for the app to get ready...")
+func portFromEnv(name string) (int, error) {
+	value, present := os.LookupEnv(name)
+	if !present || value == "" {
+		return 0, nil
+	}
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 0 || port > 65535 {
+		return 0, fmt.Errorf("%s variable is not a valid port: %q", name, value)
+	}
+	return port, nil
+}
 
-	metadata, _, err := app.GetMetadata()
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "localhost:0")
 	if err != nil {
-		return fmt.Errorf("getting metadata: %s", err)
+		return 0, err
 	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
 
-	url := fmt.Sprintf("http://localhost:3039/%s/version", metadata.ApiUrl)
+func (h *Harness) waitForAppReady(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.readyTimeout)
+	defer cancel()
+
+	fmt.Println("Waiting for the app to get ready...")
+
+	url := fmt.Sprintf("%s/%s/version", h.BaseURL(), h.metadata.ApiUrl)
 	client := &http.Client{Timeout: 100 * time.Millisecond}
 	for {
 		select {
@@ -137,6 +362,7 @@ func waitForAppReady() error {
 			if err != nil {
 				continue
 			}
+			resp.Body.Close()
 			if resp.StatusCode == http.StatusOK {
 				return nil
 			}
@@ -144,25 +370,23 @@ func waitForAppReady() error {
 	}
 }
 
-func resetDB() {
-	metadata, _, err := app.GetMetadata()
-	if err != nil {
-		fmt.Printf("getting metadata: %s", err)
-		os.Exit(1)
-	}
-
+func resetDB(metadata app.Metadata) error {
 	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
 
 	sqlScript, err := os.ReadFile("reset.sql")
 	if err != nil {
-		fmt.Printf("reading reset SQL script: %s", err)
-		os.Exit(1)
+		return fmt.Errorf("reading reset SQL script: %w", err)
 	}
+	return runResetScript(database, metadata, string(sqlScript))
+}
 
-	_, err = database.Exec(string(sqlScript))
+// runResetScript executes the reset script and checks that it reset the
+// initialization state of the app.
+func runResetScript(database *sql.DB, metadata app.Metadata, sqlScript string) error {
+	_, err := database.Exec(sqlScript)
 	if err != nil {
-		fmt.Printf("executing reset SQL script failed: %s\nScript: %s\n", err, string(sqlScript))
-		os.Exit(1)
+		return fmt.Errorf("executing reset SQL script failed: %w\nScript: %s", err, sqlScript)
 	}
 
 	row := database.QueryRow(`
@@ -172,12 +396,11 @@ func resetDB() {
 	`, metadata.Name)
 	var initialized *time.Time
 	if err = row.Scan(&initialized); err != nil {
-		fmt.Printf("executing SELECT statement: %s", err)
-		os.Exit(1)
+		return fmt.Errorf("executing SELECT statement: %w", err)
 	}
 
 	if initialized != nil {
-		fmt.Printf("checking if reset script reset the initialization state of the app: got %v, want nil", initialized)
-		os.Exit(1)
+		return fmt.Errorf("checking if reset script reset the initialization state of the app: got %v, want nil", initialized)
 	}
+	return nil
 }
@@ -0,0 +1,163 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// raceReportHeader starts every report of the race detector.
+const raceReportHeader = "WARNING: DATA RACE"
+
+func (h *Harness) goBuildCmd(output string) []string {
+	params := []string{"build", "-o", output}
+	if h.race {
+		params = append(params, "-race")
+	}
+	if h.cover {
+		params = append(params, "-cover")
+	}
+	return append(params, ".")
+}
+
+// startAppBinary compiles the app into the work directory and runs the binary.
+// Built with coverage, the binary writes its coverage data to the work
+// directory, where it is collected on stop.
+func (h *Harness) startAppBinary(ctx context.Context) error {
+	workDir, err := h.ensureWorkDir()
+	if err != nil {
+		return err
+	}
+
+	binary := filepath.Join(workDir, "app")
+	fmt.Printf("Building the binary %s...\n", binary)
+	out, err := exec.CommandContext(ctx, "go", h.goBuildCmd(binary)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("building binary: %w\n%s", err, out)
+	}
+
+	cmd := exec.Command(binary)
+	if h.cover {
+		coverDir := filepath.Join(workDir, "covdata")
+		if err := os.MkdirAll(coverDir, 0o755); err != nil {
+			return fmt.Errorf("creating coverage directory: %w", err)
+		}
+		cmd.Env = append(cmd.Env, "GOCOVERDIR="+coverDir)
+	}
+	return h.startProcess(cmd)
+}
+
+// stopAppBinary stops the binary and collects the coverage data. The data of
+// all runs of the binary are merged, so restarts are covered as well.
+func (h *Harness) stopAppBinary(ctx context.Context) error {
+	if err := h.stopProcess(ctx); err != nil {
+		return err
+	}
+	if !h.cover {
+		return nil
+	}
+	if h.lastStop.Killed {
+		// Coverage data is only written when the binary exits by itself.
+		return fmt.Errorf("app was killed, coverage data is incomplete")
+	}
+	return h.collectCoverage(ctx)
+}
+
+func (h *Harness) collectCoverage(ctx context.Context) error {
+	coverDir := filepath.Join(h.workDir, "covdata")
+	profile := h.coverProfile
+	if profile == "" {
+		profile = filepath.Join(h.workDir, "coverage.out")
+	}
+
+	out, err := exec.CommandContext(ctx, "go", "tool", "covdata", "textfmt", "-i", coverDir, "-o", profile).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("converting coverage data: %w\n%s", err, out)
+	}
+
+	percentage, err := coveragePercentage(profile)
+	if err != nil {
+		return fmt.Errorf("reading coverage profile: %w", err)
+	}
+	h.coverage = percentage
+	fmt.Printf("App coverage: %.1f%% of statements (profile: %s)\n", percentage, profile)
+	return nil
+}
+
+// Coverage returns the statement coverage of the app in percent, collected by
+// the last stop. It returns false if the app was not built with coverage.
+func (h *Harness) Coverage() (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.coverage, h.cover && h.coverage >= 0
+}
+
+// coveragePercentage computes the share of covered statements in a profile in
+// the text format written by `go tool covdata textfmt`.
+func coveragePercentage(profile string) (float64, error) {
+	file, err := os.Open(profile)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	// Blocks may be listed more than once, e.g. if several packages were merged.
+	covered := make(map[string]bool)
+	statements := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "mode:") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		numStatements, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, fmt.Errorf("parsing line %q: %w", line, err)
+		}
+		count, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, fmt.Errorf("parsing line %q: %w", line, err)
+		}
+		statements[fields[0]] = numStatements
+		covered[fields[0]] = covered[fields[0]] || count > 0
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	var total, totalCovered int
+	for block, numStatements := range statements {
+		total += numStatements
+		if covered[block] {
+			totalCovered += numStatements
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	return 100 * float64(totalCovered) / float64(total), nil
+}
@@ -0,0 +1,113 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoveragePercentage(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    float64
+		wantErr bool
+	}{
+		{
+			name: "partly covered",
+			profile: `mode: set
+example.com/app/main.go:10.13,12.2 3 1
+example.com/app/main.go:14.13,16.2 1 0
+`,
+			want: 75,
+		},
+		{
+			name: "block merged from several packages",
+			profile: `mode: atomic
+example.com/app/main.go:10.13,12.2 2 0
+example.com/app/main.go:14.13,16.2 2 0
+example.com/app/main.go:10.13,12.2 2 5
+`,
+			want: 50,
+		},
+		{
+			name: "nothing covered",
+			profile: `mode: set
+example.com/app/main.go:10.13,12.2 4 0
+`,
+			want: 0,
+		},
+		{
+			name: "fully covered",
+			profile: `mode: count
+example.com/app/main.go:10.13,12.2 4 7
+example.com/app/conf/conf.go:3.1,5.2 1 1
+`,
+			want: 100,
+		},
+		{
+			name:    "empty profile",
+			profile: "mode: set\n",
+			want:    0,
+		},
+		{
+			name: "unrelated lines",
+			profile: `mode: set
+
+example.com/app/main.go:10.13,12.2 1 1
+`,
+			want: 100,
+		},
+		{
+			name: "invalid statement count",
+			profile: `mode: set
+example.com/app/main.go:10.13,12.2 x 1
+`,
+			wantErr: true,
+		},
+		{
+			name: "invalid hit count",
+			profile: `mode: set
+example.com/app/main.go:10.13,12.2 1 y
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "coverage.out")
+			require.NoError(t, os.WriteFile(path, []byte(tt.profile), 0o600))
+
+			got, err := coveragePercentage(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
+
+func TestCoveragePercentageMissingFile(t *testing.T) {
+	_, err := coveragePercentage(filepath.Join(t.TempDir(), "missing.out"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
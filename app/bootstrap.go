@@ -0,0 +1,53 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/eliona-smart-building-assistant/go-eliona/app"
+	"github.com/eliona-smart-building-assistant/go-utils/db"
+)
+
+//go:embed bootstrap.sql
+var bootstrapSQL string
+
+// bootstrapDB creates the minimal core schema of Eliona, if it does not exist
+// yet, and installs the app the way the Eliona store does. Existing tables
+// and rows are left untouched.
+func bootstrapDB(metadata app.Metadata) error {
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+
+	if _, err := database.Exec(bootstrapSQL); err != nil {
+		return fmt.Errorf("creating core schema: %w", err)
+	}
+
+	if _, err := database.Exec(`
+		INSERT INTO public.eliona_store (app_name, category, version)
+		VALUES ($1, 'app', '1.0.0')
+		ON CONFLICT (app_name) DO NOTHING`, metadata.Name); err != nil {
+		return fmt.Errorf("adding app to store: %w", err)
+	}
+	if _, err := database.Exec(`
+		INSERT INTO public.eliona_app (app_name, category)
+		VALUES ($1, 'app')
+		ON CONFLICT (app_name) DO NOTHING`, metadata.Name); err != nil {
+		return fmt.Errorf("installing app: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+-- Minimal core schema of Eliona needed to install and test apps. Every
+-- statement is idempotent and leaves existing objects untouched, so the script
+-- can run against an empty database as well as against a full Eliona database.
+
+CREATE SCHEMA IF NOT EXISTS versioning;
+
+CREATE TABLE IF NOT EXISTS public.eliona_store
+(
+	app_name text PRIMARY KEY,
+	category text,
+	version  text,
+	metadata json,
+	icon     text
+);
+
+CREATE TABLE IF NOT EXISTS public.eliona_app
+(
+	app_name       text PRIMARY KEY,
+	category       text,
+	enable         boolean     NOT NULL DEFAULT true,
+	initialized_at timestamptz
+);
+
+CREATE TABLE IF NOT EXISTS versioning.patches
+(
+	app_name   text        NOT NULL,
+	patch_name text        NOT NULL,
+	applied_at timestamptz,
+	PRIMARY KEY (app_name, patch_name)
+);
+
+CREATE TABLE IF NOT EXISTS public.asset_type
+(
+	asset_type  text PRIMARY KEY,
+	custom      boolean NOT NULL DEFAULT true,
+	vendor      text,
+	model       text,
+	translation jsonb,
+	urldoc      text,
+	icon        text
+);
+
+CREATE TABLE IF NOT EXISTS public.attribute_schema
+(
+	id             serial PRIMARY KEY,
+	asset_type     text    NOT NULL REFERENCES public.asset_type (asset_type) ON UPDATE CASCADE ON DELETE CASCADE,
+	attribute_type text,
+	attribute      text    NOT NULL,
+	subtype        text    NOT NULL DEFAULT 'input',
+	enable         boolean NOT NULL DEFAULT true,
+	translation    jsonb,
+	unit           text,
+	precision      bigint,
+	min            double precision,
+	max            double precision,
+	viewer         boolean,
+	ar             boolean,
+	seq            bigint,
+	virtual        boolean,
+	UNIQUE (asset_type, subtype, attribute)
+);
+
+CREATE TABLE IF NOT EXISTS public.asset
+(
+	asset_id    serial PRIMARY KEY,
+	proj_id     text NOT NULL,
+	gai         text NOT NULL,
+	name        text,
+	asset_type  text NOT NULL REFERENCES public.asset_type (asset_type) ON UPDATE CASCADE,
+	description text,
+	lat         double precision,
+	lon         double precision,
+	tags        text[],
+	UNIQUE (gai, proj_id)
+);
+
+CREATE TABLE IF NOT EXISTS public.heap
+(
+	asset_id integer     NOT NULL REFERENCES public.asset (asset_id) ON DELETE CASCADE,
+	subtype  text        NOT NULL,
+	ts       timestamptz NOT NULL DEFAULT now(),
+	data     jsonb       NOT NULL DEFAULT '{}',
+	PRIMARY KEY (asset_id, subtype)
+);
+
+CREATE TABLE IF NOT EXISTS public.widget_type
+(
+	id            serial PRIMARY KEY,
+	name          text    NOT NULL UNIQUE,
+	custom        boolean NOT NULL DEFAULT true,
+	translation   jsonb,
+	icon          text,
+	with_alarm    boolean,
+	with_timespan boolean
+);
+
+CREATE TABLE IF NOT EXISTS public.widget_type_element
+(
+	id       serial PRIMARY KEY,
+	type_id  integer NOT NULL REFERENCES public.widget_type (id) ON DELETE CASCADE,
+	category text    NOT NULL,
+	seq      integer,
+	config   jsonb
+);
+
+CREATE TABLE IF NOT EXISTS public.dashboard
+(
+	dashboard_id serial PRIMARY KEY,
+	name         text NOT NULL,
+	proj_id      text NOT NULL,
+	user_id      text NOT NULL,
+	seq          integer,
+	public       boolean
+);
+
+CREATE TABLE IF NOT EXISTS public.widget
+(
+	id           serial PRIMARY KEY,
+	dashboard_id integer NOT NULL REFERENCES public.dashboard (dashboard_id) ON DELETE CASCADE,
+	type_id      integer NOT NULL REFERENCES public.widget_type (id) ON DELETE CASCADE,
+	seq          integer,
+	details      jsonb
+);
+
+CREATE TABLE IF NOT EXISTS public.widget_data
+(
+	id        serial PRIMARY KEY,
+	widget_id integer NOT NULL REFERENCES public.widget (id) ON DELETE CASCADE,
+	elem_seq  integer,
+	asset_id  integer,
+	data      jsonb
+);
+
+-- go-eliona calls fixprivilege after patching an app, to give the app user
+-- access to the objects of the app schema.
+DO
+$do$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_proc WHERE proname = 'fixprivilege') THEN
+		CREATE FUNCTION public.fixprivilege(schema_name text, user_name text) RETURNS void
+			LANGUAGE plpgsql AS
+		$fn$
+		BEGIN
+			IF EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = schema_name) THEN
+				EXECUTE format('GRANT ALL ON SCHEMA %I TO %I', schema_name, user_name);
+				EXECUTE format('GRANT ALL ON ALL TABLES IN SCHEMA %I TO %I', schema_name, user_name);
+				EXECUTE format('GRANT ALL ON ALL SEQUENCES IN SCHEMA %I TO %I', schema_name, user_name);
+				EXECUTE format('GRANT ALL ON ALL FUNCTIONS IN SCHEMA %I TO %I', schema_name, user_name);
+			END IF;
+		END;
+		$fn$;
+	END IF;
+END;
+$do$;
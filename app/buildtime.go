@@ -0,0 +1,66 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// BuildTime returns when the running app was built. For apps run from an
+// image, it is the creation time of the image, otherwise the time the harness
+// started to build and run the app.
+func (h *Harness) BuildTime() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.buildTime
+}
+
+// recordBuildTime records the build time of the app started at the given time.
+func (h *Harness) recordBuildTime(ctx context.Context, started time.Time) error {
+	container := ""
+	switch h.mode {
+	case StartModeDocker, StartModeImage:
+		container = h.containerName
+	case StartModeCompose:
+		var err error
+		if container, err = h.composeServiceContainer(ctx); err != nil {
+			return err
+		}
+	default:
+		h.buildTime = started
+		return nil
+	}
+
+	out, err := exec.CommandContext(ctx, "docker", "inspect", "-f", "{{.Image}}", container).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("inspecting app container: %w\n%s", err, out)
+	}
+	image := strings.TrimSpace(string(out))
+	out, err = exec.CommandContext(ctx, "docker", "image", "inspect", "-f", "{{.Created}}", image).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("inspecting app image: %w\n%s", err, out)
+	}
+	created, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(out)))
+	if err != nil {
+		return fmt.Errorf("parsing creation time of app image %q: %w", out, err)
+	}
+	h.buildTime = created
+	return nil
+}
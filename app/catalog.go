@@ -0,0 +1,188 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/eliona-smart-building-assistant/go-utils/db"
+)
+
+// catalogQuery lists the database objects an app could create or alter, with
+// a definition that changes whenever the object is altered. Objects not
+// belonging to a schema, like roles, have an empty schema.
+const catalogQuery = `
+	SELECT 'schema', nspname, nspname, concat_ws(' ', nspowner::regrole, nspacl)
+	FROM pg_namespace
+	UNION ALL
+	SELECT CASE c.relkind
+			WHEN 'v' THEN 'view'
+			WHEN 'm' THEN 'materialized view'
+			WHEN 'S' THEN 'sequence'
+			WHEN 'f' THEN 'foreign table'
+			WHEN 'i' THEN 'index'
+			WHEN 'I' THEN 'index'
+			ELSE 'table'
+		END,
+		n.nspname,
+		c.relname,
+		concat_ws(' ', c.relowner::regrole, c.relacl,
+			(SELECT string_agg(format('%s %s%s', a.attname, format_type(a.atttypid, a.atttypmod),
+					CASE WHEN a.attnotnull THEN ' not null' END), ', ' ORDER BY a.attnum)
+			FROM pg_attribute a
+			WHERE a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped),
+			CASE WHEN c.relkind IN ('i', 'I') THEN pg_get_indexdef(c.oid) END,
+			CASE WHEN c.relkind IN ('v', 'm') THEN pg_get_viewdef(c.oid) END)
+	FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r', 'p', 'v', 'm', 'S', 'f', 'i', 'I')
+	UNION ALL
+	SELECT 'constraint', n.nspname, c.relname || '.' || con.conname, pg_get_constraintdef(con.oid)
+	FROM pg_constraint con
+		JOIN pg_class c ON c.oid = con.conrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+	UNION ALL
+	SELECT CASE WHEN p.prokind = 'p' THEN 'procedure' ELSE 'function' END,
+		n.nspname,
+		p.proname || '(' || pg_get_function_identity_arguments(p.oid) || ')',
+		concat_ws(' ', p.proowner::regrole, p.proacl, p.prosecdef, p.proconfig, md5(p.prosrc))
+	FROM pg_proc p
+		JOIN pg_namespace n ON n.oid = p.pronamespace
+	UNION ALL
+	SELECT 'trigger', n.nspname, c.relname || '.' || t.tgname, pg_get_triggerdef(t.oid) || ' ' || t.tgenabled
+	FROM pg_trigger t
+		JOIN pg_class c ON c.oid = t.tgrelid
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE NOT t.tgisinternal
+	UNION ALL
+	SELECT 'event trigger', '', evtname, concat_ws(' ', evtevent, evtfoid::regproc, evtenabled)
+	FROM pg_event_trigger
+	UNION ALL
+	SELECT 'extension', n.nspname, e.extname, e.extversion
+	FROM pg_extension e
+		JOIN pg_namespace n ON n.oid = e.extnamespace
+	UNION ALL
+	SELECT 'role', '', rolname,
+		concat_ws(' ', rolsuper, rolinherit, rolcreaterole, rolcreatedb, rolcanlogin, rolreplication, rolbypassrls)
+	FROM pg_roles
+	UNION ALL
+	SELECT 'role membership', '', roleid::regrole || ' to ' || member::regrole, admin_option::text
+	FROM pg_auth_members`
+
+// catalogObject identifies an object of the database catalog.
+type catalogObject struct {
+	kind   string
+	schema string
+	name   string
+}
+
+func (o catalogObject) String() string {
+	if o.schema == "" || o.kind == "schema" {
+		return fmt.Sprintf("%s %s", o.kind, o.name)
+	}
+	return fmt.Sprintf("%s %s.%s", o.kind, o.schema, o.name)
+}
+
+// CatalogChanges waits until the app is initialized and returns the database
+// objects the app added, altered or removed outside its own schema since it
+// was started, e.g. "added table public.my_table" or "added role my_role".
+// Apps should only touch the core tables through the Eliona API.
+func (h *Harness) CatalogChanges(ctx context.Context) ([]string, error) {
+	h.mu.Lock()
+	running, catalogBefore := h.running, h.catalogBefore
+	h.mu.Unlock()
+
+	if !running {
+		return nil, errors.New("app is not running")
+	}
+	// The lock is not held while waiting, so that the harness can still be
+	// queried meanwhile.
+	if err := h.waitForAppInitialized(ctx); err != nil {
+		return nil, err
+	}
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+	after, err := h.queryCatalog(ctx, database)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []string
+	for object, definition := range after {
+		before, existed := catalogBefore[object]
+		switch {
+		case !existed:
+			changes = append(changes, "added "+object.String())
+		case before != definition:
+			changes = append(changes, "altered "+object.String())
+		}
+	}
+	for object := range catalogBefore {
+		if _, exists := after[object]; !exists {
+			changes = append(changes, "removed "+object.String())
+		}
+	}
+	slices.Sort(changes)
+	return changes, nil
+}
+
+// takeCatalogBaseline records the database catalog before the app is started.
+func (h *Harness) takeCatalogBaseline(ctx context.Context) error {
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+	catalog, err := h.queryCatalog(ctx, database)
+	if err != nil {
+		return err
+	}
+	h.catalogBefore = catalog
+	return nil
+}
+
+// queryCatalog returns the definitions of the objects outside of the app
+// schema, the system schemas and the schemas of the harness.
+func (h *Harness) queryCatalog(ctx context.Context, database *sql.DB) (map[catalogObject]string, error) {
+	rows, err := database.QueryContext(ctx, catalogQuery)
+	if err != nil {
+		return nil, fmt.Errorf("querying catalog: %w", err)
+	}
+	defer rows.Close()
+
+	catalog := make(map[catalogObject]string)
+	for rows.Next() {
+		var object catalogObject
+		var definition sql.NullString
+		if err := rows.Scan(&object.kind, &object.schema, &object.name, &definition); err != nil {
+			return nil, err
+		}
+		if h.ignoredSchema(object.schema) {
+			continue
+		}
+		catalog[object] = definition.String
+	}
+	return catalog, rows.Err()
+}
+
+func (h *Harness) ignoredSchema(schema string) bool {
+	return slices.Contains(h.appSchemas(), schema) ||
+		schema == "information_schema" ||
+		strings.HasPrefix(schema, "pg_") ||
+		strings.HasPrefix(schema, "integration_test_")
+}
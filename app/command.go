@@ -0,0 +1,112 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// startAppCommand runs the configured command, for apps that are not written
+// in Go. The command, its arguments and the extra environment may refer to
+// $API_SERVER_PORT and $APPNAME, e.g. to pass the port in another variable.
+func (h *Harness) startAppCommand(ctx context.Context) error {
+	if len(h.command) == 0 {
+		return errors.New("start mode command requires a command")
+	}
+
+	args := make([]string, len(h.command))
+	for i, arg := range h.command {
+		args[i] = h.expandCommandVars(arg)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = h.commandDir
+	for _, env := range h.commandEnv {
+		cmd.Env = append(cmd.Env, h.expandCommandVars(env))
+	}
+	return h.startProcess(cmd)
+}
+
+// commandVars matches the variables expanded by the harness. Other variables
+// are left untouched, so that they can be expanded by a shell.
+var commandVars = regexp.MustCompile(`\$(?:\{(API_SERVER_PORT|APPNAME)\}|(API_SERVER_PORT|APPNAME)\b)`)
+
+func (h *Harness) expandCommandVars(s string) string {
+	return commandVars.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.Contains(match, "API_SERVER_PORT") {
+			return strconv.Itoa(h.hostPort)
+		}
+		return h.metadata.Name
+	})
+}
+
+// splitCommand splits a command line into its arguments at white space.
+// Arguments may be quoted with single or double quotes. Other shell features
+// are not supported; use `sh -c '...'` if they are needed.
+func splitCommand(command string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in command %q", command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// envFlag collects KEY=VALUE pairs given by repeating a flag.
+type envFlag []string
+
+func (e *envFlag) String() string {
+	return strings.Join(*e, ",")
+}
+
+func (e *envFlag) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected KEY=VALUE, got %q", value)
+	}
+	*e = append(*e, value)
+	return nil
+}
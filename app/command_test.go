@@ -0,0 +1,88 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/eliona-smart-building-assistant/go-eliona/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+		wantErr bool
+	}{
+		{"single word", "app", []string{"app"}, false},
+		{"words", "python -m myapp", []string{"python", "-m", "myapp"}, false},
+		{"repeated and surrounding space", "  npm \t run\n start  ", []string{"npm", "run", "start"}, false},
+		{"double quotes", `sh -c "echo $PORT"`, []string{"sh", "-c", "echo $PORT"}, false},
+		{"single quotes", `sh -c 'echo "hi"'`, []string{"sh", "-c", `echo "hi"`}, false},
+		{"apostrophe in double quotes", `echo "it's"`, []string{"echo", "it's"}, false},
+		{"quotes inside word", `--name="my app"`, []string{"--name=my app"}, false},
+		{"adjacent quotes", `'a b'"c d"e`, []string{"a bc de"}, false},
+		{"empty quotes", `app ""`, []string{"app", ""}, false},
+		{"empty quotes inside word", `a""b`, []string{"ab"}, false},
+		{"backslash is literal", `C:\app\run.exe`, []string{`C:\app\run.exe`}, false},
+		{"empty", "", nil, false},
+		{"only space", "   ", nil, false},
+		{"unterminated double quote", `sh -c "echo`, nil, true},
+		{"unterminated single quote", `sh -c 'echo`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := splitCommand(tt.command)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, args)
+		})
+	}
+}
+
+func TestExpandCommandVars(t *testing.T) {
+	h := &Harness{hostPort: 41234, metadata: app.Metadata{Name: "my-app"}}
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"$API_SERVER_PORT", "41234"},
+		{"PORT=${API_SERVER_PORT}", "PORT=41234"},
+		{"--name=$APPNAME", "--name=my-app"},
+		{"${APPNAME}-$API_SERVER_PORT", "my-app-41234"},
+		{"$API_SERVER_PORTS", "$API_SERVER_PORTS"},
+		{"$HOME", "$HOME"},
+		{"plain", "plain"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, h.expandCommandVars(tt.s), "expanding %q", tt.s)
+	}
+}
+
+func TestEnvFlag(t *testing.T) {
+	var env envFlag
+	require.NoError(t, env.Set("PORT=3000"))
+	require.NoError(t, env.Set("EMPTY="))
+	assert.Error(t, env.Set("INVALID"))
+	assert.Equal(t, envFlag{"PORT=3000", "EMPTY="}, env)
+	assert.Equal(t, "PORT=3000,EMPTY=", env.String())
+}
@@ -0,0 +1,217 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// composeFileNames are the files looked up in the app directory, in the order
+// used by docker compose itself.
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+func (h *Harness) composeCmd(params ...string) []string {
+	return append([]string{"compose",
+		"-p", h.composeProject(),
+		"-f", h.composeFile,
+		"-f", filepath.Join(h.workDir, "compose.override.json")},
+		params...)
+}
+
+// startAppCompose brings up the compose project of the app. The app service
+// gets the same environment and port mapping as in StartModeDocker, by an
+// override file generated into the work directory.
+func (h *Harness) startAppCompose(ctx context.Context) error {
+	if h.composeFile == "" {
+		file, err := findComposeFile()
+		if err != nil {
+			return err
+		}
+		h.composeFile = file
+	}
+	if _, err := h.ensureWorkDir(); err != nil {
+		return err
+	}
+
+	if h.composeService == "" {
+		service, err := h.findComposeService(ctx)
+		if err != nil {
+			return fmt.Errorf("identifying app service: %w", err)
+		}
+		h.composeService = service
+	}
+	if err := h.writeComposeOverride(); err != nil {
+		return fmt.Errorf("writing compose override: %w", err)
+	}
+
+	fmt.Printf("Starting the compose project %s...\n", h.composeProject())
+	h.composeCreated = true
+	out, err := exec.CommandContext(ctx, "docker", h.composeCmd("up", "-d", "--build")...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("starting compose project: %w\n%s", err, out)
+	}
+
+	return h.monitorLogs(exec.Command("docker", h.composeCmd("logs", "-f", "--no-color", "--no-log-prefix", h.composeService)...))
+}
+
+// stopAppCompose stops the app service first, so that its shutdown can be
+// checked, and then tears the whole project down.
+func (h *Harness) stopAppCompose(ctx context.Context) error {
+	if !h.composeCreated {
+		return nil
+	}
+	// Cool down period to notice any errors occurring later after running tests.
+	time.Sleep(time.Second * 1)
+
+	var errs []error
+	started := time.Now()
+	timeout := strconv.Itoa(h.stopTimeoutSeconds())
+	out, err := exec.CommandContext(ctx, "docker", h.composeCmd("stop", "-t", timeout, h.composeService)...).CombinedOutput()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("stopping app service: %w\n%s", err, out))
+	} else {
+		h.lastStop.Duration = time.Since(started)
+		// The log stream ends together with the service.
+		h.monitors.Wait()
+		if err := h.inspectComposeExitCode(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	out, err = exec.CommandContext(ctx, "docker", h.composeCmd("down", "-v", "--rmi", "local", "--remove-orphans", "-t", timeout)...).CombinedOutput()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("tearing down compose project: %w\n%s", err, out))
+	}
+	h.composeCreated = false
+	return errors.Join(errs...)
+}
+
+// inspectComposeExitCode records the exit code of the stopped app service.
+func (h *Harness) inspectComposeExitCode(ctx context.Context) error {
+	container, err := h.composeServiceContainer(ctx)
+	if err != nil {
+		return err
+	}
+	return h.inspectExitCode(ctx, container)
+}
+
+// composeServiceContainer returns the ID of the container of the app service,
+// whether it is running or not.
+func (h *Harness) composeServiceContainer(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", h.composeCmd("ps", "-a", "-q", h.composeService)...).Output()
+	if err != nil {
+		return "", fmt.Errorf("finding app service container: %w", err)
+	}
+	containers := strings.Fields(string(out))
+	if len(containers) == 0 {
+		return "", fmt.Errorf("app service %s has no container", h.composeService)
+	}
+	return containers[0], nil
+}
+
+// composeProject returns the name of the compose project. Unlike container
+// names, project names must not contain dots.
+func (h *Harness) composeProject() string {
+	return strings.ReplaceAll(h.containerName, ".", "-")
+}
+
+func findComposeFile() (string, error) {
+	for _, name := range composeFileNames {
+		if _, err := os.Stat(name); err == nil {
+			return filepath.Abs(name)
+		}
+	}
+	return "", fmt.Errorf("no compose file found in app directory, looked for %s", strings.Join(composeFileNames, ", "))
+}
+
+// composeConfig is the part of `docker compose config --format json` needed to
+// identify the app service.
+type composeConfig struct {
+	Services map[string]struct {
+		Build *struct {
+			Context string `json:"context"`
+		} `json:"build"`
+	} `json:"services"`
+}
+
+// findComposeService returns the service built from the app directory. If no
+// service or more than one is built from there, the service has to be given
+// explicitly.
+func (h *Harness) findComposeService(ctx context.Context) (string, error) {
+	out, err := exec.CommandContext(ctx, "docker", "compose", "-f", h.composeFile, "config", "--format", "json").Output()
+	if err != nil {
+		return "", fmt.Errorf("reading compose config: %w", err)
+	}
+	var config composeConfig
+	if err := json.Unmarshal(out, &config); err != nil {
+		return "", fmt.Errorf("parsing compose config: %w", err)
+	}
+
+	var candidates []string
+	for name, service := range config.Services {
+		if service.Build != nil && filepath.Clean(service.Build.Context) == h.appLocation {
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	switch len(candidates) {
+	case 1:
+		return candidates[0], nil
+	case 0:
+		return "", errors.New("no service is built from the app directory, set the service with -app-compose-service")
+	}
+	return "", fmt.Errorf("services %s are built from the app directory, set the service with -app-compose-service", strings.Join(candidates, ", "))
+}
+
+// writeComposeOverride injects the environment and port mapping into the app
+// service. JSON is valid YAML, so compose reads the file as any other.
+func (h *Harness) writeComposeOverride() error {
+	type service struct {
+		Environment map[string]string `json:"environment"`
+		Ports       []string          `json:"ports"`
+		ExtraHosts  []string          `json:"extra_hosts"`
+	}
+	override := map[string]map[string]service{
+		"services": {
+			h.composeService: {
+				Environment: map[string]string{
+					"API_SERVER_PORT":   strconv.Itoa(h.containerPort),
+					"API_ENDPOINT":      h.containerAPIEndpoint(),
+					"API_TOKEN":         os.Getenv("API_TOKEN"),
+					"CONNECTION_STRING": h.appConnectionString(),
+					"LOG_LEVEL":         "info",
+				},
+				Ports:      []string{fmt.Sprintf("%d:%d", h.hostPort, h.containerPort)},
+				ExtraHosts: []string{"host.docker.internal:host-gateway"},
+			},
+		},
+	}
+	data, err := json.MarshalIndent(override, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(h.workDir, "compose.override.json"), data, 0o600)
+}
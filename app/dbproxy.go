@@ -0,0 +1,178 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// startDBProxy starts a TCP proxy between the app and the database given by
+// CONNECTION_STRING, which injects the database faults. Only the app connects
+// through the proxy; the harness and the checks use the database directly.
+func (h *Harness) startDBProxy() error {
+	if !h.faults {
+		return nil
+	}
+	connectionString, err := url.Parse(os.Getenv("CONNECTION_STRING"))
+	if err != nil || connectionString.Host == "" {
+		return errors.New("CONNECTION_STRING has to be a URL to inject database faults")
+	}
+	upstream := net.JoinHostPort(localHostname(connectionString.Hostname()), portOrDefault(connectionString.Port(), "5432"))
+
+	addr := "localhost:0"
+	if h.RunsInContainer() {
+		addr = ":0"
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listening for database: %w", err)
+	}
+	h.dbListener = listener
+	h.dbProxyPort = listener.Addr().(*net.TCPAddr).Port
+
+	h.proxies.Add(1)
+	go func() {
+		defer h.proxies.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			h.proxies.Add(1)
+			go func() {
+				defer h.proxies.Done()
+				h.proxyDBConn(conn, upstream)
+			}()
+		}
+	}()
+	return nil
+}
+
+// stopDBProxy stops accepting connections and drops all open ones.
+func (h *Harness) stopDBProxy() error {
+	if h.dbListener == nil {
+		return nil
+	}
+	err := h.dbListener.Close()
+	h.dbListener = nil
+	h.dbFaults.DropConnections()
+	h.proxies.Wait()
+	return err
+}
+
+func (h *Harness) proxyDBConn(conn net.Conn, upstream string) {
+	if !h.dbFaults.track(conn) {
+		return
+	}
+	defer h.dbFaults.untrack(conn)
+	defer conn.Close()
+
+	upstreamConn, err := net.DialTimeout("tcp", upstream, 10*time.Second)
+	if err != nil {
+		return
+	}
+	if !h.dbFaults.track(upstreamConn) {
+		return
+	}
+	defer h.dbFaults.untrack(upstreamConn)
+	defer upstreamConn.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		h.copyWithLatency(upstreamConn, conn)
+		// Closing both ends stops the copy in the other direction as well.
+		_ = upstreamConn.Close()
+		_ = conn.Close()
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(conn, upstreamConn)
+		_ = upstreamConn.Close()
+		_ = conn.Close()
+	}()
+	wg.Wait()
+}
+
+// copyWithLatency copies what the app sends to the database, delaying every
+// packet by the current latency.
+func (h *Harness) copyWithLatency(dst io.Writer, src io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if latency := h.dbFaults.currentLatency(); latency > 0 {
+				time.Sleep(latency)
+			}
+			if _, err := dst.Write(buf[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// appConnectionString returns CONNECTION_STRING as used by the app. With fault
+// injection, it points to the database proxy, as seen from the app. With a
+// restricted role, it uses the credentials of that role.
+func (h *Harness) appConnectionString() string {
+	connectionString := os.Getenv("CONNECTION_STRING")
+	if h.dbListener == nil && !h.dbRoleCreated {
+		return connectionString
+	}
+	u, err := url.Parse(connectionString)
+	if err != nil {
+		return connectionString
+	}
+	if h.dbRoleCreated {
+		u.User = url.UserPassword(h.dbRole(), h.dbRolePassword)
+	}
+	if h.dbListener != nil {
+		host := "localhost"
+		if h.RunsInContainer() {
+			host = "host.docker.internal"
+		}
+		u.Host = net.JoinHostPort(host, strconv.Itoa(h.dbProxyPort))
+	}
+	return u.String()
+}
+
+// localHostname returns the hostname as seen from the test process. Addresses
+// given to containers often refer to the host as host.docker.internal.
+func localHostname(hostname string) string {
+	if hostname == "host.docker.internal" {
+		return "localhost"
+	}
+	return hostname
+}
+
+func portOrDefault(port, defaultPort string) string {
+	if port == "" {
+		return defaultPort
+	}
+	return port
+}
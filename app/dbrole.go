@@ -0,0 +1,169 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+
+	"github.com/eliona-smart-building-assistant/go-utils/db"
+	"github.com/lib/pq"
+)
+
+// restrictedRoleGrants are the privileges of the restricted role on the public
+// tables documented for apps. Grants on missing tables are skipped.
+var restrictedRoleGrants = []struct {
+	privileges string
+	table      string
+}{
+	{"SELECT, UPDATE", "public.eliona_app"},
+	{"SELECT", "public.eliona_store"},
+	{"SELECT, INSERT, UPDATE", "versioning.patches"},
+}
+
+// createRestrictedRole creates the role the app connects with when started
+// with WithRestrictedRole. Besides the documented public tables, the role owns
+// the app schema. It may create schemas, since the app creates its own schema;
+// other schemas it creates are reported by CatalogChanges.
+func (h *Harness) createRestrictedRole(ctx context.Context) error {
+	if !h.restrictRole {
+		return nil
+	}
+	connectionString, err := url.Parse(os.Getenv("CONNECTION_STRING"))
+	if err != nil || connectionString.Host == "" {
+		return errors.New("CONNECTION_STRING has to be a URL to use a restricted role")
+	}
+	if h.dbRolePassword == "" {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return fmt.Errorf("generating password: %w", err)
+		}
+		h.dbRolePassword = hex.EncodeToString(b)
+	}
+
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var databaseName string
+	if err := tx.QueryRowContext(ctx, `SELECT current_database()`).Scan(&databaseName); err != nil {
+		return fmt.Errorf("getting database name: %w", err)
+	}
+	role := pq.QuoteIdentifier(h.dbRole())
+	statements := []string{
+		fmt.Sprintf(`CREATE ROLE %s LOGIN NOSUPERUSER NOCREATEDB NOCREATEROLE PASSWORD %s`, role, pq.QuoteLiteral(h.dbRolePassword)),
+		fmt.Sprintf(`GRANT CONNECT, CREATE ON DATABASE %s TO %s`, pq.QuoteIdentifier(databaseName), role),
+	}
+	for _, schema := range []string{"public", "versioning"} {
+		statements = append(statements, fmt.Sprintf(`
+			DO $$
+			BEGIN
+				IF EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = %s) THEN
+					GRANT USAGE ON SCHEMA %s TO %s;
+				END IF;
+			END
+			$$`, pq.QuoteLiteral(schema), pq.QuoteIdentifier(schema), role))
+	}
+	statements = append(statements, fmt.Sprintf(`
+		DO $$
+		BEGIN
+			IF to_regprocedure('public.fixprivilege(text, text)') IS NOT NULL THEN
+				GRANT EXECUTE ON FUNCTION public.fixprivilege(text, text) TO %s;
+			END IF;
+		END
+		$$`, role))
+	for _, grant := range restrictedRoleGrants {
+		statements = append(statements, fmt.Sprintf(`
+			DO $$
+			BEGIN
+				IF to_regclass(%s) IS NOT NULL THEN
+					GRANT %s ON %s TO %s;
+				END IF;
+			END
+			$$`, pq.QuoteLiteral(grant.table), grant.privileges, grant.table, role))
+	}
+	// The reset script drops the app schema, but an app schema created
+	// elsewhere has to be owned by the role.
+	for _, schema := range h.appSchemas() {
+		statements = append(statements, fmt.Sprintf(`
+			DO $$
+			BEGIN
+				IF EXISTS (SELECT 1 FROM pg_namespace WHERE nspname = %s) THEN
+					ALTER SCHEMA %s OWNER TO %s;
+				END IF;
+			END
+			$$`, pq.QuoteLiteral(schema), pq.QuoteIdentifier(schema), role))
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("creating restricted role: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.dbRoleCreated = true
+	return nil
+}
+
+// dropRestrictedRole drops the restricted role. The objects it owns, like the
+// app schema, are handed over to the user of CONNECTION_STRING, so that they
+// can still be checked after the app was stopped.
+func (h *Harness) dropRestrictedRole() error {
+	if !h.dbRoleCreated {
+		return nil
+	}
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+
+	role := pq.QuoteIdentifier(h.dbRole())
+	if _, err := database.Exec(fmt.Sprintf(`
+		REASSIGN OWNED BY %[1]s TO CURRENT_USER;
+		DROP OWNED BY %[1]s;
+		DROP ROLE %[1]s;`, role)); err != nil {
+		return fmt.Errorf("dropping restricted role: %w", err)
+	}
+	h.dbRoleCreated = false
+	return nil
+}
+
+// dbRole returns the name of the restricted role.
+func (h *Harness) dbRole() string {
+	return "integration_test_app_" + h.runID
+}
+
+// permissionError matches the errors Postgres reports if the role lacks a
+// privilege (SQLSTATE 42501), as written by common drivers, e.g.
+// "pq: permission denied for table asset" or
+// "ERROR: must be owner of table asset (SQLSTATE 42501)".
+var permissionError = regexp.MustCompile(`(?:\bpq|\bERROR|\bFATAL):\s+(?:permission denied (?:for|to)|must be owner of)\s|\bSQLSTATE[\s:=]*42501\b|\bInsufficientPrivilege\b`)
+
+// isPermissionError reports whether a log line tells that the restricted role
+// lacks a privilege in the database.
+func isPermissionError(line string) bool {
+	return permissionError.MatchString(line)
+}
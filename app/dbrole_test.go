@@ -0,0 +1,44 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPermissionError(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"ERROR\t2024-01-02 15:04:05.000000\tAssets\tError upserting asset: pq: permission denied for table asset", true},
+		{`level=error msg="query failed" err="ERROR: permission denied for schema public (SQLSTATE 42501)"`, true},
+		{"WARNING\t2024-01-02 15:04:05.000000\tInit\tpq: must be owner of table eliona_app", true},
+		{"pq: permission denied to create extension \"uuid-ossp\"", true},
+		{`{"level":"info","error":"SQLSTATE 42501"}`, true},
+		{"psycopg2.errors.InsufficientPrivilege: permission denied for table asset", true},
+		{"ERROR\t2024-01-02 15:04:05.000000\tVendor\tGET /devices: 403 permission denied", false},
+		{"open /etc/app/config.json: permission denied", false},
+		{"ERROR\t2024-01-02 15:04:05.000000\tMAIN\tmust be owner of the device to change it", false},
+		{"processed 142501 rows", false},
+		{"pq: relation \"asset\" does not exist", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, isPermissionError(tt.line), "line %q", tt.line)
+	}
+}
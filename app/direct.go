@@ -16,77 +16,132 @@
 package app
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"github.com/eliona-smart-building-assistant/go-eliona/app"
-	_ "github.com/lib/pq"
 	"io"
 	"os"
 	"os/exec"
-	"strings"
-)
+	"path/filepath"
+	"time"
 
-// Assuming Dockerfile is present in the current directory
-var (
-	goRunCmdParams = []string{"run", "."}
-	goRunCmd       *exec.Cmd
+	_ "github.com/lib/pq"
 )
 
-func startAppDirectly() {
-	metadata, _, err := app.GetMetadata()
+// outputCloseDelay is the time the output of the killed app may stay open. A
+// process that left the process group of the app survives the kill and keeps
+// the output open.
+const outputCloseDelay = 2 * time.Second
+
+// startAppDirectly compiles the app like `go run .` does and runs the binary
+// itself. Unlike `go run`, the binary gets the signals of the harness and
+// reports its real exit code.
+func (h *Harness) startAppDirectly(ctx context.Context) error {
+	workDir, err := h.ensureWorkDir()
+	if err != nil {
+		return err
+	}
+
+	binary := filepath.Join(workDir, "app")
+	out, err := exec.CommandContext(ctx, "go", "build", "-o", binary, ".").CombinedOutput()
 	if err != nil {
-		fmt.Printf("getting metadata: %s", err)
-		os.Exit(1)
+		return fmt.Errorf("building app: %w\n%s", err, out)
 	}
+	return h.startProcess(exec.Command(binary))
+}
 
-	goRunCmd = exec.Command("go", goRunCmdParams...)
-	goRunCmd.Env = os.Environ()
-	goRunCmd.Env = append(goRunCmd.Env, fmt.Sprintf("APPNAME=%s", metadata.Name))
-	goRunCmd.Env = append(goRunCmd.Env, "API_SERVER_PORT=3039")
+// startProcess starts the app and monitors its output. The app gets its own
+// process group, so that it can be stopped together with everything it started,
+// like helper processes or the app started by a shell command.
+func (h *Harness) startProcess(cmd *exec.Cmd) error {
+	cmd.Env = append(os.Environ(), cmd.Env...)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("APPNAME=%s", h.metadata.Name))
+	cmd.Env = append(cmd.Env, fmt.Sprintf("API_SERVER_PORT=%d", h.hostPort))
+	cmd.Env = append(cmd.Env, fmt.Sprintf("CONNECTION_STRING=%s", h.appConnectionString()))
+	setProcessGroup(cmd)
 
 	// Create pipes to capture stdout and stderr
-	stdoutPipe, err := goRunCmd.StdoutPipe()
+	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
-		fmt.Println("Failed to create stdout pipe:", err)
-		return
+		return fmt.Errorf("creating stdout pipe: %w", err)
 	}
 
-	stderrPipe, err := goRunCmd.StderrPipe()
+	stderrPipe, err := cmd.StderrPipe()
 	if err != nil {
-		fmt.Println("Failed to create stderr pipe:", err)
-		return
+		return fmt.Errorf("creating stderr pipe: %w", err)
 	}
 
 	// Start the command
-	if err := goRunCmd.Start(); err != nil {
-		fmt.Printf("Failed to start app directly: %s", err)
-		os.Exit(1)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting app process: %w", err)
 	}
+	h.cmd = cmd
+	h.cmdOutput = []io.Closer{stdoutPipe, stderrPipe}
 
-	go monitorDirectOutput(stdoutPipe)
-	go monitorDirectOutput(stderrPipe)
+	h.monitors.Add(2)
+	go h.monitorProcessOutput(StreamStdout, stdoutPipe)
+	go h.monitorProcessOutput(StreamStderr, stderrPipe)
+	return nil
+}
 
-	if err := waitForAppReady(); err != nil {
-		fmt.Printf("waiting for command to get ready: %v\n", err)
-		os.Exit(1)
+// stopProcess sends SIGTERM to the app and waits for it to exit. If the app
+// does not exit within the stop timeout, its whole process group is killed.
+func (h *Harness) stopProcess(ctx context.Context) error {
+	if h.cmd == nil {
+		return nil
 	}
+	defer func() {
+		h.cmd = nil
+		h.cmdOutput = nil
+	}()
 
-}
-
-func stopAppDirectly() {
-	err := goRunCmd.Process.Kill()
-	if err != nil {
-		fmt.Println("Failed to send SIGKILL:", err)
-		os.Exit(1)
+	pgid := h.cmd.Process.Pid
+	started := time.Now()
+	if err := terminateGroup(pgid); err != nil {
+		return fmt.Errorf("sending SIGTERM: %w", err)
 	}
-}
 
-func monitorDirectOutput(pipe io.Reader) {
-	scanner := bufio.NewScanner(pipe)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "FATAL") || strings.HasPrefix(line, "ERROR") {
-			panic(fmt.Sprintf("Container log error: %s\n", line))
+	cmd := h.cmd
+	done := make(chan struct{})
+	go func() {
+		// The pipes are closed once all processes in the group exited.
+		h.monitors.Wait()
+		_ = cmd.Wait()
+		close(done)
+	}()
+
+	killed := false
+	select {
+	case <-done:
+	case <-time.After(h.stopTimeout):
+		killed = true
+	case <-ctx.Done():
+		killed = true
+	}
+	// Make sure nothing started by the app survives, even if it exited gracefully.
+	if err := killGroup(pgid); err != nil {
+		return fmt.Errorf("sending SIGKILL: %w", err)
+	}
+	var err error
+	select {
+	case <-done:
+	case <-time.After(outputCloseDelay):
+		err = errors.New("output of the app is still open after it was killed, a process started by the app left its process group")
+		for _, output := range h.cmdOutput {
+			_ = output.Close()
 		}
+		<-done
+	}
+
+	if !killed {
+		h.lastStop.ExitCode = cmd.ProcessState.ExitCode()
 	}
+	h.lastStop.Killed = killed
+	h.lastStop.Duration = time.Since(started)
+	return err
+}
+
+func (h *Harness) monitorProcessOutput(stream string, pipe io.Reader) {
+	defer h.monitors.Done()
+	h.monitorOutput(pipe, func(line string) { h.handleLogLine(stream, line) })
 }
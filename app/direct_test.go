@@ -0,0 +1,80 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+//go:build unix
+
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stopperApp prints "ready" and exits with code 3 on SIGTERM, after printing a
+// line that looks like the exit status reported by `go run`.
+const stopperApp = `package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	fmt.Println("ready")
+	<-signals
+	fmt.Fprintln(os.Stderr, "exit status 7")
+	os.Exit(3)
+}
+`
+
+func TestStopAppDirectly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Building an app is slow")
+	}
+	appDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(appDir, "go.mod"), []byte("module example.com/stopper\n\ngo 1.24\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(appDir, "main.go"), []byte(stopperApp), 0o644))
+	t.Chdir(appDir)
+
+	h := &Harness{
+		mode:        StartModeDirect,
+		stopTimeout: 10 * time.Second,
+		workDir:     t.TempDir(),
+		logs:        newLogBuffer(),
+		logParser:   AutoLogParser{LevelKey: defaultLevelKey},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	require.NoError(t, h.startAppDirectly(ctx))
+	_, err := h.logs.WaitFor(ctx, regexp.MustCompile(`^ready$`))
+	require.NoError(t, err)
+
+	h.lastStop = StopResult{ExitCode: -1}
+	require.NoError(t, h.stopProcess(ctx))
+	assert.Equal(t, 3, h.lastStop.ExitCode, "exit code of the app, not the one printed")
+	assert.False(t, h.lastStop.Killed)
+	assert.True(t, h.logs.Contains("exit status 7"))
+}
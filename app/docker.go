@@ -16,11 +16,15 @@
 package app
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	_ "github.com/lib/pq"
@@ -31,93 +35,176 @@ import (
 // If this will be resolved in the future, it would be nice to use the SDK.
 
 // Assuming Dockerfile is present in the current directory
-var (
-	dockerBuildCmd = []string{"build", ".",
-		"-t", "go-app-test"}
-	dockerRunCmd = []string{"run",
-		"--name", "go-app-test-container",
+func (h *Harness) dockerBuildCmd() []string {
+	return []string{"build", ".",
+		"-t", h.imageName}
+}
+
+func (h *Harness) dockerRunCmd() []string {
+	return []string{"run",
+		"--name", h.containerName,
 		"-d",
 		"-i",
-		"-p", "3039:3000",
-		"-e", "API_ENDPOINT=$API_ENDPOINT",
-		"-e", "API_TOKEN=$API_TOKEN",
-		"-e", "CONNECTION_STRING=$CONNECTION_STRING",
+		"-p", fmt.Sprintf("%d:%d", h.hostPort, h.containerPort),
+		"-e", fmt.Sprintf("API_SERVER_PORT=%d", h.containerPort),
+		"-e", fmt.Sprintf("API_ENDPOINT=%s", h.containerAPIEndpoint()),
+		"-e", fmt.Sprintf("API_TOKEN=%s", os.Getenv("API_TOKEN")),
+		"-e", fmt.Sprintf("CONNECTION_STRING=%s", h.appConnectionString()),
 		"-e", "LOG_LEVEL=info",
 		"--add-host", "host.docker.internal:host-gateway",
-		"go-app-test"}
-	dockerLogsCmd = []string{"logs", "-f", "go-app-test-container"}
-	dockerStopCmd = []string{"stop", "go-app-test-container"}
-	dockerRmCmd   = []string{"rm", "go-app-test-container"}
-)
+		h.imageName}
+}
 
-func startAppContainer() {
-	out, err := exec.Command("docker", dockerRmCmd...).CombinedOutput()
-	if err != nil {
-		fmt.Printf("Failed to remove docker image: %s\n%s", err, out)
-	}
+func (h *Harness) dockerLogsCmd() []string {
+	return []string{"logs", "-f", h.containerName}
+}
 
-	// Build and run docker image
-	fmt.Println("Building the image...")
-	out, err = exec.Command("docker", dockerBuildCmd...).CombinedOutput()
-	if err != nil {
-		fmt.Printf("Failed to build docker image: %s\n%s", err, out)
-		os.Exit(1)
+func (h *Harness) dockerStopCmd() []string {
+	return []string{"stop", "-t", fmt.Sprintf("%d", h.stopTimeoutSeconds()), h.containerName}
+}
+
+// stopTimeoutSeconds returns the stop timeout in the whole seconds docker
+// expects. It is rounded up, so that a timeout below a second does not kill
+// the app right away.
+func (h *Harness) stopTimeoutSeconds() int {
+	return int(math.Ceil(h.stopTimeout.Seconds()))
+}
+
+func dockerInspectExitCodeCmd(container string) []string {
+	return []string{"inspect", "-f", "{{.State.ExitCode}}", container}
+}
+
+func (h *Harness) dockerRmCmd() []string {
+	return []string{"rm", "-f", h.containerName}
+}
+
+func (h *Harness) dockerRmiCmd() []string {
+	return []string{"rmi", h.imageName}
+}
+
+// startAppContainer builds the image from the Dockerfile and runs it. The
+// image is built once and kept for restarts until the harness is closed. In
+// StartModeImage, the given image is run as is, so it is neither built nor
+// removed afterwards.
+func (h *Harness) startAppContainer(ctx context.Context) error {
+	if h.mode == StartModeDocker && !h.imageCreated {
+		// Build and run docker image
+		fmt.Printf("Building the image %s...\n", h.imageName)
+		out, err := exec.CommandContext(ctx, "docker", h.dockerBuildCmd()...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("building docker image: %w\n%s", err, out)
+		}
+		h.imageCreated = true
+	} else {
+		fmt.Printf("Running the image %s...\n", h.imageName)
 	}
 
-	out, err = exec.Command("docker", expandEnvInArray(dockerRunCmd...)...).CombinedOutput()
+	out, err := exec.CommandContext(ctx, "docker", h.dockerRunCmd()...).CombinedOutput()
 	if err != nil {
-		fmt.Printf("Failed to start docker container: %s\n%s", err, out)
-		os.Exit(1)
+		// docker run may fail after creating the container, e.g. if the port
+		// is already allocated. The container would keep the name of this run.
+		_ = exec.Command("docker", h.dockerRmCmd()...).Run()
+		return fmt.Errorf("starting docker container: %w\n%s", err, out)
 	}
+	h.containerCreated = true
 
-	go monitorDockerLogs()
-
-	if err := waitForAppReady(); err != nil {
-		fmt.Printf("waiting for container to get ready: %v\n", err)
-		os.Exit(1)
-	}
+	return h.monitorLogs(exec.Command("docker", h.dockerLogsCmd()...))
 }
 
-func stopAppContainer() {
+func (h *Harness) stopAppContainer(ctx context.Context) error {
 	// Cool down period to notice any errors occurring later after running tests.
 	time.Sleep(time.Second * 1)
-	teardownDocker()
+	return h.teardownDocker(ctx)
 }
 
-func expandEnvInArray(arr ...string) []string {
-	result := make([]string, len(arr))
-	for i, str := range arr {
-		result[i] = os.ExpandEnv(str)
+// teardownDocker stops and removes the container created by this run.
+// Containers of other runs are never touched.
+func (h *Harness) teardownDocker(ctx context.Context) error {
+	var errs []error
+	if h.containerCreated {
+		started := time.Now()
+		out, err := exec.CommandContext(ctx, "docker", h.dockerStopCmd()...).CombinedOutput()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("stopping docker container: %w\n%s", err, out))
+		} else {
+			h.lastStop.Duration = time.Since(started)
+			// The log stream ends together with the container.
+			h.monitors.Wait()
+			if err := h.inspectExitCode(ctx, h.containerName); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		out, err = exec.CommandContext(ctx, "docker", h.dockerRmCmd()...).CombinedOutput()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("removing docker container: %w\n%s", err, out))
+		}
+		h.containerCreated = false
 	}
-	return result
+	return errors.Join(errs...)
 }
 
-func teardownDocker() {
-	out, err := exec.Command("docker", dockerStopCmd...).CombinedOutput()
+// removeImage removes the image built by this run. Images of other runs are
+// never touched.
+func (h *Harness) removeImage(ctx context.Context) error {
+	if !h.imageCreated {
+		return nil
+	}
+	out, err := exec.CommandContext(ctx, "docker", h.dockerRmiCmd()...).CombinedOutput()
 	if err != nil {
-		fmt.Printf("Failed to stop docker container: %s\n%s", err, out)
-		os.Exit(1)
+		return fmt.Errorf("removing docker image: %w\n%s", err, out)
 	}
+	h.imageCreated = false
+	return nil
 }
 
-func monitorDockerLogs() {
-	defer teardownDocker()
+// inspectExitCode records the exit code of the stopped container. Docker
+// reports 137 if the container had to be killed after the stop timeout.
+func (h *Harness) inspectExitCode(ctx context.Context, container string) error {
+	out, err := exec.CommandContext(ctx, "docker", dockerInspectExitCodeCmd(container)...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("inspecting docker container %s: %w\n%s", container, err, out)
+	}
+	exitCode, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	if err != nil {
+		return fmt.Errorf("parsing exit code of docker container %s %q: %w", container, out, err)
+	}
+	h.lastStop.ExitCode = exitCode
+	h.lastStop.Killed = exitCode == 137
+	return nil
+}
 
-	logCmd := exec.Command("docker", dockerLogsCmd...)
-	// All output is written to stderr.
+// monitorLogs starts the command following the log of a container and
+// monitors its output until the command exits.
+func (h *Harness) monitorLogs(logCmd *exec.Cmd) error {
+	// The go-eliona logger writes all output to stderr, but apps may use stdout as well.
+	stdout, err := logCmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("creating log stdout pipe: %w", err)
+	}
 	stderr, err := logCmd.StderrPipe()
 	if err != nil {
-		panic(fmt.Sprintf("Log stderr pipe: %v\n", err))
+		return fmt.Errorf("creating log stderr pipe: %w", err)
 	}
 	if err := logCmd.Start(); err != nil {
-		panic(fmt.Sprintf("Starting log: %v\n", err))
+		return fmt.Errorf("starting log: %w", err)
 	}
 
-	scanner := bufio.NewScanner(stderr)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "FATAL") || strings.HasPrefix(line, "ERROR") {
-			panic(fmt.Sprintf("Container log error: %s\n", line))
-		}
-	}
+	var streams sync.WaitGroup
+	streams.Add(2)
+	go func() {
+		defer streams.Done()
+		h.monitorOutput(stdout, func(line string) { h.handleLogLine(StreamStdout, line) })
+	}()
+	go func() {
+		defer streams.Done()
+		h.monitorOutput(stderr, func(line string) { h.handleLogLine(StreamStderr, line) })
+	}()
+
+	h.monitors.Add(1)
+	go func() {
+		defer h.monitors.Done()
+		streams.Wait()
+		_ = logCmd.Wait()
+	}()
+	return nil
 }
@@ -0,0 +1,239 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// connFaults are the faults common to the Eliona API and the database. They
+// are safe for concurrent use and take effect immediately.
+type connFaults struct {
+	mu          sync.Mutex
+	latency     time.Duration
+	outageUntil time.Time
+	conns       map[net.Conn]struct{}
+}
+
+// SetLatency delays every request to the API, or every packet sent to the
+// database, by the given duration. Zero removes the latency.
+func (f *connFaults) SetLatency(latency time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = latency
+}
+
+// Outage makes the dependency unavailable for the given duration. Open
+// connections are dropped and new connections are closed right away.
+func (f *connFaults) Outage(duration time.Duration) {
+	f.mu.Lock()
+	f.outageUntil = time.Now().Add(duration)
+	f.mu.Unlock()
+	f.DropConnections()
+}
+
+// DropConnections closes all open connections of the app to the dependency.
+func (f *connFaults) DropConnections() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for conn := range f.conns {
+		_ = conn.Close()
+	}
+	clear(f.conns)
+}
+
+// Reset removes all faults.
+func (f *connFaults) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.latency = 0
+	f.outageUntil = time.Time{}
+}
+
+func (f *connFaults) currentLatency() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.latency
+}
+
+func (f *connFaults) down() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Now().Before(f.outageUntil)
+}
+
+// track registers an open connection, so that it can be dropped. It reports
+// false and closes the connection if the dependency is down.
+func (f *connFaults) track(conn net.Conn) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if time.Now().Before(f.outageUntil) {
+		_ = conn.Close()
+		return false
+	}
+	if f.conns == nil {
+		f.conns = make(map[net.Conn]struct{})
+	}
+	f.conns[conn] = struct{}{}
+	return true
+}
+
+func (f *connFaults) untrack(conn net.Conn) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.conns, conn)
+}
+
+// APIFaultInjector controls the faults injected between the app and the
+// Eliona API. Faults are only injected with WithFaultInjection.
+type APIFaultInjector struct {
+	connFaults
+	failures []int
+}
+
+// FailRequests answers the next count requests with the given status code,
+// e.g. http.StatusServiceUnavailable or http.StatusTooManyRequests, instead of
+// passing them to the API.
+func (f *APIFaultInjector) FailRequests(count int, status int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for range count {
+		f.failures = append(f.failures, status)
+	}
+}
+
+// DropRequests closes the connection of the next count requests without
+// sending a response.
+func (f *APIFaultInjector) DropRequests(count int) {
+	f.FailRequests(count, 0)
+}
+
+// Reset removes all faults, including the pending failed requests.
+func (f *APIFaultInjector) Reset() {
+	f.connFaults.Reset()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = nil
+}
+
+// nextFailure returns the status code the next request fails with, 0 if it is
+// dropped, or -1 if it is passed to the API.
+func (f *APIFaultInjector) nextFailure() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.failures) == 0 {
+		return -1
+	}
+	status := f.failures[0]
+	f.failures = f.failures[1:]
+	return status
+}
+
+// connState tracks the connections of the app to the API server.
+func (f *APIFaultInjector) connState(conn net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		f.track(conn)
+	case http.StateClosed, http.StateHijacked:
+		f.untrack(conn)
+	}
+}
+
+// DBFaultInjector controls the faults injected between the app and the
+// database. Faults are only injected with WithFaultInjection.
+type DBFaultInjector struct {
+	connFaults
+}
+
+// APIFaults returns the faults injected between the app started by RunApp or
+// StartApp and the Eliona API.
+func APIFaults() *APIFaultInjector {
+	if defaultHarness == nil {
+		return &APIFaultInjector{}
+	}
+	return defaultHarness.APIFaults()
+}
+
+// DBFaults returns the faults injected between the app started by RunApp or
+// StartApp and the database.
+func DBFaults() *DBFaultInjector {
+	if defaultHarness == nil {
+		return &DBFaultInjector{}
+	}
+	return defaultHarness.DBFaults()
+}
+
+// APIFaults returns the faults injected between the app and the Eliona API.
+func (h *Harness) APIFaults() *APIFaultInjector {
+	return h.apiFaults
+}
+
+// DBFaults returns the faults injected between the app and the database.
+func (h *Harness) DBFaults() *DBFaultInjector {
+	return h.dbFaults
+}
+
+// injectAPIFaults applies the API faults to every request before passing it
+// to next.
+func (h *Harness) injectAPIFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if latency := h.apiFaults.currentLatency(); latency > 0 {
+			if err := sleep(r.Context(), latency); err != nil {
+				return
+			}
+		}
+		status := 0
+		if !h.apiFaults.down() {
+			status = h.apiFaults.nextFailure()
+		}
+		switch status {
+		case -1:
+			next.ServeHTTP(w, r)
+		case 0:
+			dropConnection(w)
+		default:
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"message":"fault injected by the app integration tests"}`))
+		}
+	})
+}
+
+// dropConnection closes the connection of the request without a response.
+func dropConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// Without access to the connection, aborting the handler closes it.
+		panic(http.ErrAbortHandler)
+	}
+	_ = conn.Close()
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
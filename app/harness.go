@@ -0,0 +1,656 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eliona-smart-building-assistant/go-eliona/app"
+)
+
+const (
+	defaultContainerPort = 3000
+	defaultReadyTimeout  = time.Minute
+	defaultStopTimeout   = 10 * time.Second
+	defaultShutdown      = 5 * time.Second
+)
+
+// Harness starts an app, keeps track of the resources created for it and
+// tears them down again. Each harness has its own run ID, so that several
+// harnesses can share a machine and each only cleans up after itself.
+type Harness struct {
+	mode           string
+	appLocation    string
+	hostPort       int
+	containerPort  int
+	readyTimeout   time.Duration
+	stopTimeout    time.Duration
+	shutdown       time.Duration
+	race           bool
+	cover          bool
+	coverProfile   string
+	composeFile    string
+	composeService string
+	command        []string
+	commandDir     string
+	commandEnv     []string
+	mock           bool
+	recordAPI      bool
+	faults         bool
+	bootstrap      bool
+	snapshot       bool
+	restrictRole   bool
+
+	runID         string
+	image         string
+	imageName     string
+	containerName string
+	metadata      app.Metadata
+	workDir       string
+	coverage      float64
+	buildTime     time.Time
+
+	mu               sync.Mutex
+	running          bool
+	imageCreated     bool
+	containerCreated bool
+	composeCreated   bool
+	cmd              *exec.Cmd
+	cmdOutput        []io.Closer
+	monitors         sync.WaitGroup
+	lastStop         StopResult
+	apiServer        *http.Server
+	apiPort          int
+	apiBasePath      string
+	mockDB           *sql.DB
+	apiEndpoint      string
+	apiEndpointSet   bool
+	apiCalls         *APICallLog
+	apiFaults        *APIFaultInjector
+	dbFaults         *DBFaultInjector
+	dbListener       net.Listener
+	dbProxyPort      int
+	proxies          sync.WaitGroup
+	snapshotted      []snapshotTable
+	resetBaseline    *resetState
+	catalogBefore    map[catalogObject]string
+	dbRolePassword   string
+	dbRoleCreated    bool
+
+	logs          *LogBuffer
+	logParser     LogParser
+	logMu         sync.Mutex
+	logErrors     []logError
+	allowlist     []*regexp.Regexp
+	allowlistFile string
+	watches       []*watch
+}
+
+// Option configures a Harness.
+type Option func(*Harness)
+
+// WithMode sets the start mode, e.g. StartModeDocker.
+func WithMode(mode string) Option {
+	return func(h *Harness) {
+		h.mode = strings.ToLower(mode)
+	}
+}
+
+// WithImage sets the reference of the prebuilt image run in StartModeImage.
+func WithImage(image string) Option {
+	return func(h *Harness) {
+		h.image = image
+	}
+}
+
+// WithAppLocation sets the path to the app directory.
+func WithAppLocation(path string) Option {
+	return func(h *Harness) {
+		h.appLocation = path
+	}
+}
+
+// WithHostPort sets the host port the app API is exposed on. Zero picks a free port.
+func WithHostPort(port int) Option {
+	return func(h *Harness) {
+		h.hostPort = port
+	}
+}
+
+// WithContainerPort sets the port the app API listens on inside the container.
+func WithContainerPort(port int) Option {
+	return func(h *Harness) {
+		h.containerPort = port
+	}
+}
+
+// WithReadyTimeout sets the time the app has to get ready after start.
+func WithReadyTimeout(timeout time.Duration) Option {
+	return func(h *Harness) {
+		h.readyTimeout = timeout
+	}
+}
+
+// WithStopTimeout sets the grace period the app has to stop before it is killed.
+func WithStopTimeout(timeout time.Duration) Option {
+	return func(h *Harness) {
+		h.stopTimeout = timeout
+	}
+}
+
+// WithShutdownBudget sets the time a graceful shutdown may take.
+func WithShutdownBudget(budget time.Duration) Option {
+	return func(h *Harness) {
+		h.shutdown = budget
+	}
+}
+
+// WithErrorAllowlist sets patterns of ERROR and FATAL lines the app may log
+// without failing the tests.
+func WithErrorAllowlist(patterns ...*regexp.Regexp) Option {
+	return func(h *Harness) {
+		h.allowlist = append(h.allowlist, patterns...)
+	}
+}
+
+// WithRace builds the app with the race detector in StartModeBinary. Any race
+// reported by the app fails the run.
+func WithRace(race bool) Option {
+	return func(h *Harness) {
+		h.race = race
+	}
+}
+
+// WithCover builds the app with coverage instrumentation in StartModeBinary.
+// The coverage is collected when the app stops gracefully.
+func WithCover(cover bool) Option {
+	return func(h *Harness) {
+		h.cover = cover
+	}
+}
+
+// WithCoverProfile sets the file the merged coverage profile is written to.
+// By default, it is written to a temporary directory.
+func WithCoverProfile(path string) Option {
+	return func(h *Harness) {
+		h.coverProfile = path
+	}
+}
+
+// WithComposeFile sets the compose file brought up in StartModeCompose. By
+// default, the compose file in the app directory is used.
+func WithComposeFile(path string) Option {
+	return func(h *Harness) {
+		h.composeFile = path
+	}
+}
+
+// WithComposeService sets the service of the compose project running the app.
+// By default, it is the only service built from the app directory.
+func WithComposeService(service string) Option {
+	return func(h *Harness) {
+		h.composeService = service
+	}
+}
+
+// WithCommand sets the command started in StartModeCommand.
+func WithCommand(name string, args ...string) Option {
+	return func(h *Harness) {
+		h.command = append([]string{name}, args...)
+	}
+}
+
+// WithCommandDir sets the working directory of the command started in
+// StartModeCommand. Relative paths are relative to the app directory.
+func WithCommandDir(dir string) Option {
+	return func(h *Harness) {
+		h.commandDir = dir
+	}
+}
+
+// WithCommandEnv adds KEY=VALUE pairs to the environment of the command
+// started in StartModeCommand.
+func WithCommandEnv(env ...string) Option {
+	return func(h *Harness) {
+		h.commandEnv = append(h.commandEnv, env...)
+	}
+}
+
+// WithMock serves the built-in mock of the Eliona API and points API_ENDPOINT
+// to it, instead of using the Eliona API given by API_ENDPOINT. All calls to
+// the mock are recorded.
+func WithMock(enabled bool) Option {
+	return func(h *Harness) {
+		h.mock = enabled
+	}
+}
+
+// WithAPIRecording puts a proxy between the app and the Eliona API given by
+// API_ENDPOINT, which records all calls to the API.
+func WithAPIRecording(enabled bool) Option {
+	return func(h *Harness) {
+		h.recordAPI = enabled
+	}
+}
+
+// WithFaultInjection puts proxies between the app and the Eliona API and
+// between the app and the database, which inject the faults configured by
+// APIFaults and DBFaults.
+func WithFaultInjection(enabled bool) Option {
+	return func(h *Harness) {
+		h.faults = enabled
+	}
+}
+
+// WithDBBootstrap creates a minimal core schema of Eliona in the database
+// before the app is started, so that the app can be tested against an empty
+// Postgres. Existing tables are left untouched.
+func WithDBBootstrap(enabled bool) Option {
+	return func(h *Harness) {
+		h.bootstrap = enabled
+	}
+}
+
+// WithSnapshot takes a snapshot of the database once the app is initialized,
+// which RestoreSnapshot restores without restarting the app.
+func WithSnapshot(enabled bool) Option {
+	return func(h *Harness) {
+		h.snapshot = enabled
+	}
+}
+
+// WithRestrictedRole starts the app with a database role that owns the app
+// schema and may only use the public tables documented for apps, instead of
+// the user of CONNECTION_STRING. Database errors about missing privileges
+// logged by the app fail the run, whatever their level.
+func WithRestrictedRole(enabled bool) Option {
+	return func(h *Harness) {
+		h.restrictRole = enabled
+	}
+}
+
+// WithLogParser sets the parser used to detect the level of lines written by
+// the app. By default, the format is detected for each line.
+func WithLogParser(parser LogParser) Option {
+	return func(h *Harness) {
+		h.logParser = parser
+	}
+}
+
+// WithErrorAllowlistFile reads patterns of allowed ERROR and FATAL lines from
+// a file with one regular expression per line. By default, error-allowlist.txt
+// in the app directory is read if present.
+func WithErrorAllowlistFile(path string) Option {
+	return func(h *Harness) {
+		h.allowlistFile = path
+	}
+}
+
+// NewHarness returns a harness configured by the environment variables and the given options.
+func NewHarness(options ...Option) (*Harness, error) {
+	h := &Harness{
+		mode:          startModeFromEnv(),
+		image:         os.Getenv("APP_IMAGE"),
+		commandDir:    os.Getenv("START_WORKDIR"),
+		appLocation:   ".",
+		containerPort: defaultContainerPort,
+		readyTimeout:  defaultReadyTimeout,
+		stopTimeout:   defaultStopTimeout,
+		shutdown:      defaultShutdown,
+		coverage:      -1,
+		logs:          newLogBuffer(),
+		apiCalls:      &APICallLog{},
+		apiFaults:     &APIFaultInjector{},
+		dbFaults:      &DBFaultInjector{},
+		logParser:     AutoLogParser{LevelKey: defaultLevelKey},
+	}
+
+	port, err := portFromEnv("APP_PORT")
+	if err != nil {
+		return nil, err
+	}
+	h.hostPort = port
+	if port, err = portFromEnv("APP_CONTAINER_PORT"); err != nil {
+		return nil, err
+	} else if port != 0 {
+		h.containerPort = port
+	}
+
+	if command, present := os.LookupEnv("START_COMMAND"); present {
+		if h.command, err = splitCommand(command); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	// Start changes the working directory, so a relative path would not survive a restart.
+	if h.appLocation, err = filepath.Abs(h.appLocation); err != nil {
+		return nil, fmt.Errorf("resolving app location: %w", err)
+	}
+
+	if h.composeFile != "" {
+		if h.composeFile, err = filepath.Abs(h.composeFile); err != nil {
+			return nil, fmt.Errorf("resolving compose file path: %w", err)
+		}
+	}
+	if h.coverProfile != "" {
+		if h.coverProfile, err = filepath.Abs(h.coverProfile); err != nil {
+			return nil, fmt.Errorf("resolving coverage profile path: %w", err)
+		}
+	}
+
+	if h.allowlistFile == "" {
+		defaultFile := filepath.Join(h.appLocation, defaultErrorAllowlistFile)
+		if _, err := os.Stat(defaultFile); err == nil {
+			h.allowlistFile = defaultFile
+		}
+	}
+	if h.allowlistFile != "" {
+		patterns, err := readErrorAllowlist(h.allowlistFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading error allowlist: %w", err)
+		}
+		h.allowlist = append(h.allowlist, patterns...)
+	}
+
+	if h.runID, err = newRunID(); err != nil {
+		return nil, fmt.Errorf("generating run ID: %w", err)
+	}
+	return h, nil
+}
+
+var defaultHarness *Harness
+
+// Default returns the harness started by RunApp or StartApp, or else the
+// first harness started by Start. The package-level functions and the checks
+// of package test use it.
+func Default() *Harness {
+	return defaultHarness
+}
+
+// Start resets the database, starts the app and waits until it is ready. If
+// Start fails, Stop or Close must still be called to clean up partially created resources.
+func (h *Harness) Start(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.running {
+		return errors.New("app is already running")
+	}
+	if defaultHarness == nil {
+		defaultHarness = h
+	}
+
+	if err := checkEnvVars(h.mock); err != nil {
+		return fmt.Errorf("checking environment variables: %w", err)
+	}
+	if err := os.Chdir(h.appLocation); err != nil {
+		return fmt.Errorf("chdir to %s: %w", h.appLocation, err)
+	}
+
+	metadata, _, err := app.GetMetadata()
+	if err != nil {
+		return fmt.Errorf("getting metadata: %w", err)
+	}
+	h.metadata = metadata
+	name := fmt.Sprintf("%s-test-%s", sanitizeName(metadata.Name), h.runID)
+	h.imageName = name
+	h.containerName = name
+	if h.mode == StartModeImage {
+		if h.image == "" {
+			return errors.New("start mode image requires an image reference")
+		}
+		h.imageName = h.image
+	}
+
+	if h.hostPort == 0 {
+		if h.hostPort, err = freePort(); err != nil {
+			return fmt.Errorf("finding free port: %w", err)
+		}
+	}
+
+	if h.bootstrap {
+		if err := bootstrapDB(metadata); err != nil {
+			return fmt.Errorf("bootstrapping database: %w", err)
+		}
+	}
+	if err := resetDB(metadata); err != nil {
+		return fmt.Errorf("resetting database: %w", err)
+	}
+	if err := h.takeResetBaseline(ctx); err != nil {
+		return fmt.Errorf("recording database state before install: %w", err)
+	}
+
+	h.running = true
+	if err := h.createRestrictedRole(ctx); err != nil {
+		return err
+	}
+	if err := h.takeCatalogBaseline(ctx); err != nil {
+		return fmt.Errorf("recording database catalog: %w", err)
+	}
+	if err := h.startAPI(); err != nil {
+		return err
+	}
+	if err := h.startDBProxy(); err != nil {
+		return err
+	}
+	started := time.Now()
+	switch h.mode {
+	case StartModeDirect:
+		err = h.startAppDirectly(ctx)
+	case StartModeDocker, StartModeImage:
+		err = h.startAppContainer(ctx)
+	case StartModeBinary:
+		err = h.startAppBinary(ctx)
+	case StartModeCompose:
+		err = h.startAppCompose(ctx)
+	case StartModeCommand:
+		err = h.startAppCommand(ctx)
+	default:
+		err = fmt.Errorf("unknown start mode %q", h.mode)
+	}
+	if err != nil {
+		return err
+	}
+	if err := h.recordBuildTime(ctx, started); err != nil {
+		return fmt.Errorf("getting build time of app: %w", err)
+	}
+
+	if err := h.waitForAppReady(ctx); err != nil {
+		return fmt.Errorf("waiting for app to get ready: %w", err)
+	}
+	if h.snapshot {
+		if err := h.waitForAppInitialized(ctx); err != nil {
+			return fmt.Errorf("waiting for app to get initialized: %w", err)
+		}
+		if err := h.takeSnapshot(ctx); err != nil {
+			return fmt.Errorf("taking database snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// Stop stops the app and removes all resources created by Start. It returns
+// an error if stopping failed. Errors logged by the app while stopping are
+// reported by LastStop, other errors not attributed to a watching test by
+// Close. Calling Stop on a harness that is not running does nothing. The
+// harness can be started again after it was stopped.
+func (h *Harness) Stop(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.running {
+		return nil
+	}
+	h.running = false
+
+	h.lastStop = StopResult{ExitCode: -1}
+	loggedBefore := h.logErrorCount()
+	var err error
+	switch h.mode {
+	case StartModeDirect, StartModeCommand:
+		err = h.stopProcess(ctx)
+	case StartModeDocker, StartModeImage:
+		err = h.stopAppContainer(ctx)
+	case StartModeBinary:
+		err = h.stopAppBinary(ctx)
+	case StartModeCompose:
+		err = h.stopAppCompose(ctx)
+	}
+	// The API and database proxies are stopped after the app, so that the app can use it while shutting down.
+	err = errors.Join(err, h.stopAPI(ctx), h.stopDBProxy(), h.dropRestrictedRole())
+	h.lastStop.LogErrors = h.takeLogErrorsSince(loggedBefore)
+	return err
+}
+
+// Close removes the image, the temporary files and the database snapshot of
+// the harness. It stops the app first if it is still running. Besides errors
+// of stopping and cleaning up, it returns the errors logged by the app that
+// were neither allowed nor attributed to a watching test, including those
+// logged while it stopped the app. The harness must not be started again
+// afterwards.
+func (h *Harness) Close(ctx context.Context) error {
+	h.mu.Lock()
+	running := h.running
+	h.mu.Unlock()
+	err := h.Stop(ctx)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if running && len(h.lastStop.LogErrors) > 0 {
+		err = errors.Join(err, fmt.Errorf("app logged errors while stopping:\n%s", strings.Join(h.lastStop.LogErrors, "\n")))
+	}
+	err = errors.Join(err, h.logError(), h.removeImage(ctx), h.dropSnapshot())
+	if h.workDir != "" {
+		err = errors.Join(err, os.RemoveAll(h.workDir))
+		h.workDir = ""
+	}
+	return err
+}
+
+// StopResult describes how the app behaved when it was stopped.
+type StopResult struct {
+	// ExitCode is the exit code of the app, or -1 if it is not known.
+	ExitCode int
+	// Killed reports whether the app had to be killed after the grace period.
+	Killed bool
+	// Duration is the time from the stop signal until the app exited.
+	Duration time.Duration
+	// LogErrors are the ERROR and FATAL lines logged while the app was stopping.
+	LogErrors []string
+}
+
+// LastStop returns how the app behaved the last time it was stopped.
+func (h *Harness) LastStop() StopResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.lastStop
+}
+
+// BaseURL returns the URL under which the API of the app is reachable from
+// the test process, e.g. "http://localhost:41234".
+func (h *Harness) BaseURL() string {
+	return fmt.Sprintf("http://localhost:%d", h.hostPort)
+}
+
+// ShutdownBudget returns the time a graceful shutdown may take.
+func (h *Harness) ShutdownBudget() time.Duration {
+	return h.shutdown
+}
+
+// Mode returns the start mode of the app.
+func (h *Harness) Mode() string {
+	return h.mode
+}
+
+// RunID returns the random ID distinguishing this harness from others.
+func (h *Harness) RunID() string {
+	return h.runID
+}
+
+// ensureWorkDir creates the temporary directory of the harness. It is kept
+// until Close, so that coverage data survives restarts.
+func (h *Harness) ensureWorkDir() (string, error) {
+	if h.workDir != "" {
+		return h.workDir, nil
+	}
+	workDir, err := os.MkdirTemp("", fmt.Sprintf("%s-test-%s-", sanitizeName(h.metadata.Name), h.runID))
+	if err != nil {
+		return "", fmt.Errorf("creating work directory: %w", err)
+	}
+	h.workDir = workDir
+	return workDir, nil
+}
+
+// appSchemas returns the possible names of the schema of the app. Apps name
+// their schema like the app, with either dashes or underscores.
+func (h *Harness) appSchemas() []string {
+	schemas := []string{h.metadata.Name}
+	for _, schema := range []string{
+		strings.ReplaceAll(h.metadata.Name, "-", "_"),
+		strings.ReplaceAll(h.metadata.Name, "_", "-"),
+	} {
+		if !slices.Contains(schemas, schema) {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+func newRunID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sanitizeName turns the app name into a valid docker image and container name.
+func sanitizeName(name string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune('-')
+		}
+	}
+	sanitized := strings.Trim(sb.String(), "-_.")
+	if sanitized == "" {
+		return "app"
+	}
+	return sanitized
+}
@@ -0,0 +1,237 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// defaultErrorAllowlistFile is read from the app directory if no other
+// allowlist is given.
+const defaultErrorAllowlistFile = "error-allowlist.txt"
+
+// logError is an ERROR or FATAL line logged by the app that was not allowed.
+type logError struct {
+	line string
+	// watch is the watched test the error is attributed to, nil if none.
+	watch    *watch
+	reported bool
+}
+
+// watch is a test the errors logged by the app are attributed to.
+type watch struct {
+	t            testing.TB
+	expectations []*regexp.Regexp
+}
+
+// Watch makes errors logged by the app while t is running fail t. Tests are
+// not watched automatically; errors not logged during any watched test are
+// reported when the harness is closed at the end of the run.
+//
+// Errors are attributed to the innermost watched test, so a watched test
+// running subtests gets the errors logged while no watched subtest runs. The
+// errors of watched tests running in parallel cannot be told apart; they are
+// reported when the harness is closed as well. Tests running in parallel with
+// a watched test have to be watched, too.
+func Watch(t testing.TB) {
+	if defaultHarness != nil {
+		defaultHarness.Watch(t)
+	}
+}
+
+// ExpectErrorLog allows the app to log ERROR and FATAL lines matching the
+// pattern while t is running. It is meant for tests deliberately triggering
+// errors. The test is watched as by Watch, and only errors attributed to it
+// or its subtests are allowed.
+func ExpectErrorLog(t testing.TB, pattern string) {
+	if defaultHarness != nil {
+		defaultHarness.ExpectErrorLog(t, pattern)
+	}
+}
+
+// Watch makes errors logged by the app while t is running fail t.
+func (h *Harness) Watch(t testing.TB) {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	h.watch(t)
+}
+
+// ExpectErrorLog allows the app to log ERROR and FATAL lines matching the
+// pattern while t is running.
+func (h *Harness) ExpectErrorLog(t testing.TB, pattern string) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		t.Fatalf("Compiling expected error pattern: %v", err)
+	}
+
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	w := h.watch(t)
+	w.expectations = append(w.expectations, re)
+}
+
+// watch returns the watch of t, starting one if t is not watched yet. The
+// caller must hold logMu.
+func (h *Harness) watch(t testing.TB) *watch {
+	for _, w := range h.watches {
+		if w.t == t {
+			return w
+		}
+	}
+	w := &watch{t: t}
+	h.watches = append(h.watches, w)
+	t.Cleanup(func() {
+		for _, line := range h.endWatch(w) {
+			t.Errorf("App logged error: %s", line)
+		}
+	})
+	return w
+}
+
+// endWatch stops attributing errors to the watched test and returns the
+// errors attributed to it.
+func (h *Harness) endWatch(w *watch) []string {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	h.watches = slices.DeleteFunc(h.watches, func(other *watch) bool { return other == w })
+	var lines []string
+	for i := range h.logErrors {
+		if h.logErrors[i].watch == w && !h.logErrors[i].reported {
+			h.logErrors[i].reported = true
+			lines = append(lines, h.logErrors[i].line)
+		}
+	}
+	return lines
+}
+
+// currentWatch returns the innermost watched test if the running watched
+// tests are nested in each other, and nil if none is running or some of
+// them run in parallel. The caller must hold logMu.
+func (h *Harness) currentWatch() *watch {
+	if len(h.watches) == 0 {
+		return nil
+	}
+	for i := 1; i < len(h.watches); i++ {
+		parent, child := h.watches[i-1].t.Name(), h.watches[i].t.Name()
+		if !strings.HasPrefix(child, parent+"/") {
+			return nil
+		}
+	}
+	return h.watches[len(h.watches)-1]
+}
+
+// recordLogError remembers an error logged by the app unless it is allowed.
+func (h *Harness) recordLogError(line string) {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	w := h.currentWatch()
+	if h.isAllowed(line, w) {
+		return
+	}
+	h.logErrors = append(h.logErrors, logError{line: line, watch: w})
+}
+
+// appendLogError remembers an error regardless of the allowlist.
+func (h *Harness) appendLogError(line string) {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	h.logErrors = append(h.logErrors, logError{line: line, watch: h.currentWatch()})
+}
+
+// isAllowed reports whether the line matches the allowlist or an expectation
+// of the watched test the line is attributed to or of the tests it is nested
+// in. The caller must hold logMu.
+func (h *Harness) isAllowed(line string, current *watch) bool {
+	for _, re := range h.allowlist {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	if current == nil {
+		return false
+	}
+	// The running watches are nested, so all of them contain the current one.
+	for _, w := range h.watches {
+		for _, re := range w.expectations {
+			if re.MatchString(line) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (h *Harness) logErrorCount() int {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	return len(h.logErrors)
+}
+
+// takeLogErrorsSince returns the errors recorded after the first count ones
+// that were not reported yet, and marks them as reported.
+func (h *Harness) takeLogErrorsSince(count int) []string {
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	var lines []string
+	for i := count; i < len(h.logErrors); i++ {
+		if h.logErrors[i].reported {
+			continue
+		}
+		h.logErrors[i].reported = true
+		lines = append(lines, h.logErrors[i].line)
+	}
+	return lines
+}
+
+// logError returns all errors not reported to a test yet, and marks them as reported.
+func (h *Harness) logError() error {
+	lines := h.takeLogErrorsSince(0)
+	if len(lines) == 0 {
+		return nil
+	}
+	return fmt.Errorf("app logged errors:\n%s", strings.Join(lines, "\n"))
+}
+
+// readErrorAllowlist reads patterns from a file with one regular expression per
+// line. Empty lines and lines starting with # are ignored.
+func readErrorAllowlist(path string) ([]*regexp.Regexp, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []*regexp.Regexp
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		re, err := regexp.Compile(line)
+		if err != nil {
+			return nil, fmt.Errorf("compiling pattern %q: %w", line, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, scanner.Err()
+}
@@ -0,0 +1,129 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTest records the errors reported to a test and runs its cleanups when
+// it finishes.
+type fakeTest struct {
+	testing.TB
+	name     string
+	cleanups []func()
+	errors   []string
+}
+
+func (f *fakeTest) Name() string           { return f.name }
+func (f *fakeTest) Cleanup(cleanup func()) { f.cleanups = append(f.cleanups, cleanup) }
+func (f *fakeTest) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTest) finish() {
+	for i := len(f.cleanups) - 1; i >= 0; i-- {
+		f.cleanups[i]()
+	}
+}
+
+func closeErrors(h *Harness) string {
+	if err := h.logError(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func TestWatchAttributesErrorsToInnermostTest(t *testing.T) {
+	h := &Harness{}
+	parent := &fakeTest{name: "TestApp"}
+	child := &fakeTest{name: "TestApp/TestSync"}
+
+	h.recordLogError("ERROR before")
+	h.Watch(parent)
+	h.recordLogError("ERROR in parent")
+	h.Watch(child)
+	h.Watch(child)
+	h.recordLogError("ERROR in child")
+	child.finish()
+	h.recordLogError("ERROR in parent again")
+	parent.finish()
+	h.recordLogError("ERROR after")
+
+	assert.Equal(t, []string{"App logged error: ERROR in child"}, child.errors)
+	assert.Equal(t, []string{"App logged error: ERROR in parent", "App logged error: ERROR in parent again"}, parent.errors)
+	assert.Equal(t, "app logged errors:\nERROR before\nERROR after", closeErrors(h))
+}
+
+func TestWatchDoesNotAttributeErrorsOfParallelTests(t *testing.T) {
+	h := &Harness{}
+	parent := &fakeTest{name: "TestApp"}
+	first := &fakeTest{name: "TestApp/TestA"}
+	second := &fakeTest{name: "TestApp/TestB"}
+
+	h.Watch(parent)
+	h.Watch(first)
+	h.ExpectErrorLog(second, `invalid`)
+	h.recordLogError("ERROR invalid config")
+	h.recordLogError("ERROR sync failed")
+	second.finish()
+	first.finish()
+	parent.finish()
+
+	assert.Empty(t, first.errors)
+	assert.Empty(t, second.errors)
+	assert.Empty(t, parent.errors)
+	assert.Equal(t, "app logged errors:\nERROR invalid config\nERROR sync failed", closeErrors(h))
+}
+
+func TestExpectErrorLog(t *testing.T) {
+	h := &Harness{allowlist: []*regexp.Regexp{regexp.MustCompile(`connection reset`)}}
+	parent := &fakeTest{name: "TestApp"}
+	child := &fakeTest{name: "TestApp/TestInvalidConfig"}
+
+	h.ExpectErrorLog(parent, `timeout`)
+	h.ExpectErrorLog(child, `invalid`)
+	h.recordLogError("ERROR invalid config")
+	h.recordLogError("ERROR timeout")
+	h.recordLogError("ERROR connection reset")
+	h.appendLogError("ERROR invalid config, permission denied")
+	child.finish()
+	h.recordLogError("ERROR invalid config")
+	h.recordLogError("ERROR timeout")
+	parent.finish()
+
+	assert.Equal(t, []string{"App logged error: ERROR invalid config, permission denied"}, child.errors)
+	assert.Equal(t, []string{"App logged error: ERROR invalid config"}, parent.errors)
+	assert.Empty(t, closeErrors(h))
+}
+
+func TestTakeLogErrorsSince(t *testing.T) {
+	h := &Harness{}
+	test := &fakeTest{name: "TestApp"}
+	h.Watch(test)
+	h.recordLogError("ERROR before stopping")
+	count := h.logErrorCount()
+	h.recordLogError("ERROR while stopping")
+
+	assert.Equal(t, []string{"ERROR while stopping"}, h.takeLogErrorsSince(count))
+	assert.Empty(t, h.takeLogErrorsSince(count), "errors are reported once")
+	test.finish()
+	assert.Equal(t, []string{"App logged error: ERROR before stopping"}, test.errors)
+}
@@ -0,0 +1,265 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Normalized log levels, as written by the go-eliona logger.
+const (
+	LevelTrace   = "TRACE"
+	LevelDebug   = "DEBUG"
+	LevelInfo    = "INFO"
+	LevelWarning = "WARNING"
+	LevelError   = "ERROR"
+	LevelFatal   = "FATAL"
+)
+
+// Log formats selectable by the -app-log-format flag.
+const (
+	LogFormatAuto   = "auto"
+	LogFormatText   = "text"
+	LogFormatJSON   = "json"
+	LogFormatLogfmt = "logfmt"
+)
+
+const defaultLevelKey = "level"
+
+// LogEntry is the result of parsing a line written by the app.
+type LogEntry struct {
+	// Level is one of the normalized levels, or empty if the line has none.
+	Level   string
+	Message string
+}
+
+// IsError reports whether the entry has level ERROR or FATAL.
+func (e LogEntry) IsError() bool {
+	return e.Level == LevelError || e.Level == LevelFatal
+}
+
+// LogParser extracts the level and message from a line written by the app. It
+// returns false if the line is not in the format understood by the parser.
+type LogParser interface {
+	Parse(line string) (LogEntry, bool)
+}
+
+// NewLogParser returns the parser for the format. The level key is used by the
+// structured formats and defaults to "level".
+func NewLogParser(format, levelKey string) (LogParser, error) {
+	if levelKey == "" {
+		levelKey = defaultLevelKey
+	}
+	switch strings.ToLower(format) {
+	case LogFormatAuto, "":
+		return AutoLogParser{LevelKey: levelKey}, nil
+	case LogFormatText:
+		return TextLogParser{}, nil
+	case LogFormatJSON:
+		return JSONLogParser{LevelKey: levelKey}, nil
+	case LogFormatLogfmt:
+		return LogfmtLogParser{LevelKey: levelKey}, nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+// TextLogParser parses the plain text format of the go-eliona logger, where
+// the line starts with the level, e.g.
+// "ERROR	2024-01-02 15:04:05.000000	MAIN	Something failed".
+type TextLogParser struct{}
+
+var textLevels = []string{LevelTrace, LevelDebug, LevelInfo, LevelWarning, LevelError, LevelFatal}
+
+func (TextLogParser) Parse(line string) (LogEntry, bool) {
+	for _, level := range textLevels {
+		if !strings.HasPrefix(line, level) {
+			continue
+		}
+		// The date and tag precede the message.
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) == 4 {
+			return LogEntry{Level: level, Message: fields[3]}, true
+		}
+		return LogEntry{Level: level, Message: line}, true
+	}
+	return LogEntry{}, false
+}
+
+// JSONLogParser parses lines of structured loggers writing one JSON object per
+// line, with the level in the field LevelKey.
+type JSONLogParser struct {
+	LevelKey string
+}
+
+func (p JSONLogParser) Parse(line string) (LogEntry, bool) {
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return LogEntry{}, false
+	}
+	var entry LogEntry
+	switch level := fields[p.LevelKey].(type) {
+	case string:
+		entry.Level = normalizeLevel(level)
+	case float64:
+		entry.Level = numericLevel(level)
+	}
+	for _, key := range []string{"msg", "message"} {
+		if message, ok := fields[key].(string); ok {
+			entry.Message = message
+			break
+		}
+	}
+	return entry, true
+}
+
+// LogfmtLogParser parses lines in logfmt, e.g. `level=error msg="sync failed"`,
+// with the level in the field LevelKey.
+type LogfmtLogParser struct {
+	LevelKey string
+}
+
+func (p LogfmtLogParser) Parse(line string) (LogEntry, bool) {
+	fields, ok := parseLogfmt(line)
+	if !ok {
+		return LogEntry{}, false
+	}
+	level, hasLevel := fields[p.LevelKey]
+	if !hasLevel {
+		return LogEntry{}, false
+	}
+	entry := LogEntry{Level: normalizeLevel(level)}
+	for _, key := range []string{"msg", "message"} {
+		if message, ok := fields[key]; ok {
+			entry.Message = message
+			break
+		}
+	}
+	return entry, true
+}
+
+// AutoLogParser detects the format of each line. Lines starting with a level
+// are parsed in the plain text format of the go-eliona logger, JSON objects
+// and logfmt lines containing the level key as such.
+type AutoLogParser struct {
+	LevelKey string
+}
+
+func (p AutoLogParser) Parse(line string) (LogEntry, bool) {
+	if entry, ok := (TextLogParser{}).Parse(line); ok {
+		return entry, true
+	}
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		if entry, ok := (JSONLogParser{LevelKey: p.LevelKey}).Parse(line); ok {
+			return entry, true
+		}
+	}
+	if strings.Contains(line, p.LevelKey+"=") {
+		if entry, ok := (LogfmtLogParser{LevelKey: p.LevelKey}).Parse(line); ok {
+			return entry, true
+		}
+	}
+	return LogEntry{}, false
+}
+
+// normalizeLevel maps the level names used by common loggers to the levels of
+// the go-eliona logger. It returns an empty string for unknown names.
+func normalizeLevel(level string) string {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "trace":
+		return LevelTrace
+	case "debug", "dbug":
+		return LevelDebug
+	case "info", "information":
+		return LevelInfo
+	case "warn", "warning":
+		return LevelWarning
+	case "error", "err", "eror":
+		return LevelError
+	case "fatal", "panic", "dpanic", "crit", "critical", "alert", "emerg", "emergency":
+		return LevelFatal
+	}
+	return ""
+}
+
+// numericLevel maps the numeric levels of loggers like pino and bunyan.
+func numericLevel(level float64) string {
+	switch {
+	case level >= 60:
+		return LevelFatal
+	case level >= 50:
+		return LevelError
+	case level >= 40:
+		return LevelWarning
+	case level >= 30:
+		return LevelInfo
+	case level >= 20:
+		return LevelDebug
+	}
+	return LevelTrace
+}
+
+// parseLogfmt splits a logfmt line into its key-value pairs. Values may be
+// quoted with double quotes. It returns false if the line contains no pair.
+func parseLogfmt(line string) (map[string]string, bool) {
+	fields := make(map[string]string)
+	i := 0
+	for i < len(line) {
+		for i < len(line) && unicode.IsSpace(rune(line[i])) {
+			i++
+		}
+		start := i
+		for i < len(line) && line[i] != '=' && !unicode.IsSpace(rune(line[i])) {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			i++
+			continue
+		}
+		if i >= len(line) || line[i] != '=' {
+			// A key without value.
+			fields[key] = ""
+			continue
+		}
+		i++
+
+		var value string
+		if i < len(line) && line[i] == '"' {
+			var sb strings.Builder
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				sb.WriteByte(line[i])
+				i++
+			}
+			i++
+			value = sb.String()
+		} else {
+			start = i
+			for i < len(line) && !unicode.IsSpace(rune(line[i])) {
+				i++
+			}
+			value = line[start:i]
+		}
+		fields[key] = value
+	}
+	return fields, len(fields) > 0
+}
@@ -0,0 +1,253 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLogParser(t *testing.T) {
+	tests := []struct {
+		format   string
+		levelKey string
+		want     LogParser
+		wantErr  bool
+	}{
+		{"", "", AutoLogParser{LevelKey: "level"}, false},
+		{"auto", "severity", AutoLogParser{LevelKey: "severity"}, false},
+		{"text", "", TextLogParser{}, false},
+		{"JSON", "", JSONLogParser{LevelKey: "level"}, false},
+		{"logfmt", "lvl", LogfmtLogParser{LevelKey: "lvl"}, false},
+		{"xml", "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			parser, err := NewLogParser(tt.format, tt.levelKey)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, parser)
+		})
+	}
+}
+
+func TestTextLogParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   LogEntry
+		wantOK bool
+	}{
+		{"go-eliona line", "ERROR\t2024-01-02 15:04:05.000000\tMAIN\tSomething failed", LogEntry{Level: LevelError, Message: "Something failed"}, true},
+		{"message with tabs", "INFO\t2024-01-02 15:04:05.000000\tMAIN\ta\tb", LogEntry{Level: LevelInfo, Message: "a\tb"}, true},
+		{"level only", "WARNING something", LogEntry{Level: LevelWarning, Message: "WARNING something"}, true},
+		{"fatal", "FATAL\t2024-01-02 15:04:05.000000\tMAIN\tgiving up", LogEntry{Level: LevelFatal, Message: "giving up"}, true},
+		{"lower case level", "error\tsomething", LogEntry{}, false},
+		{"level not at start", "an ERROR occurred", LogEntry{}, false},
+		{"empty", "", LogEntry{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := TextLogParser{}.Parse(tt.line)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, entry)
+		})
+	}
+}
+
+func TestJSONLogParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		levelKey string
+		line     string
+		want     LogEntry
+		wantOK   bool
+	}{
+		{"zap", "level", `{"level":"error","msg":"sync failed"}`, LogEntry{Level: LevelError, Message: "sync failed"}, true},
+		{"message key", "level", `{"level":"WARN","message":"slow"}`, LogEntry{Level: LevelWarning, Message: "slow"}, true},
+		{"msg before message", "level", `{"level":"info","msg":"a","message":"b"}`, LogEntry{Level: LevelInfo, Message: "a"}, true},
+		{"pino numeric level", "level", `{"level":50,"msg":"failed"}`, LogEntry{Level: LevelError, Message: "failed"}, true},
+		{"custom level key", "severity", `{"severity":"CRITICAL","msg":"down"}`, LogEntry{Level: LevelFatal, Message: "down"}, true},
+		{"unknown level", "level", `{"level":"verbose","msg":"x"}`, LogEntry{Message: "x"}, true},
+		{"no level", "level", `{"msg":"x"}`, LogEntry{Message: "x"}, true},
+		{"not an object", "level", `["level","error"]`, LogEntry{}, false},
+		{"invalid", "level", `{"level":"error"`, LogEntry{}, false},
+		{"text", "level", "ERROR something", LogEntry{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := JSONLogParser{LevelKey: tt.levelKey}.Parse(tt.line)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, entry)
+		})
+	}
+}
+
+func TestLogfmtLogParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		levelKey string
+		line     string
+		want     LogEntry
+		wantOK   bool
+	}{
+		{"quoted message", "level", `time=2024-01-02T15:04:05Z level=error msg="sync failed"`, LogEntry{Level: LevelError, Message: "sync failed"}, true},
+		{"bare message", "level", `level=warn msg=slow`, LogEntry{Level: LevelWarning, Message: "slow"}, true},
+		{"message key", "level", `level=info message="started"`, LogEntry{Level: LevelInfo, Message: "started"}, true},
+		{"custom level key", "lvl", `lvl=eror msg=x`, LogEntry{Level: LevelError, Message: "x"}, true},
+		{"quoted level", "level", `level="fatal" msg=x`, LogEntry{Level: LevelFatal, Message: "x"}, true},
+		{"unknown level", "level", `level=verbose msg=x`, LogEntry{Message: "x"}, true},
+		{"no level", "level", `msg="sync failed"`, LogEntry{}, false},
+		{"other level key", "level", `lvl=error msg=x`, LogEntry{}, false},
+		{"empty", "level", ``, LogEntry{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := LogfmtLogParser{LevelKey: tt.levelKey}.Parse(tt.line)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, entry)
+		})
+	}
+}
+
+func TestAutoLogParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   LogEntry
+		wantOK bool
+	}{
+		{"text", "ERROR\t2024-01-02 15:04:05.000000\tMAIN\tfailed", LogEntry{Level: LevelError, Message: "failed"}, true},
+		{"json", `{"level":"error","msg":"failed"}`, LogEntry{Level: LevelError, Message: "failed"}, true},
+		{"indented json", `  {"level":"info","msg":"ok"}`, LogEntry{Level: LevelInfo, Message: "ok"}, true},
+		{"logfmt", `level=error msg="failed"`, LogEntry{Level: LevelError, Message: "failed"}, true},
+		{"brace but logfmt", `{ level=debug msg=x`, LogEntry{Level: LevelDebug, Message: "x"}, true},
+		{"plain line", "listening on port 3000", LogEntry{}, false},
+		{"plain line with equals", "a=b c=d", LogEntry{}, false},
+		{"error word", "an error occurred", LogEntry{}, false},
+	}
+	parser := AutoLogParser{LevelKey: defaultLevelKey}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry, ok := parser.Parse(tt.line)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, entry)
+		})
+	}
+}
+
+func TestLogEntryIsError(t *testing.T) {
+	tests := []struct {
+		level string
+		want  bool
+	}{
+		{LevelTrace, false},
+		{LevelDebug, false},
+		{LevelInfo, false},
+		{LevelWarning, false},
+		{LevelError, true},
+		{LevelFatal, true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, LogEntry{Level: tt.level}.IsError(), "level %q", tt.level)
+	}
+}
+
+func TestNormalizeLevel(t *testing.T) {
+	tests := []struct {
+		level string
+		want  string
+	}{
+		{"trace", LevelTrace},
+		{"DEBUG", LevelDebug},
+		{"dbug", LevelDebug},
+		{"Info", LevelInfo},
+		{"information", LevelInfo},
+		{"warn", LevelWarning},
+		{"WARNING", LevelWarning},
+		{"error", LevelError},
+		{"err", LevelError},
+		{"eror", LevelError},
+		{" error ", LevelError},
+		{"fatal", LevelFatal},
+		{"panic", LevelFatal},
+		{"dpanic", LevelFatal},
+		{"crit", LevelFatal},
+		{"emerg", LevelFatal},
+		{"notice", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, normalizeLevel(tt.level), "level %q", tt.level)
+	}
+}
+
+func TestNumericLevel(t *testing.T) {
+	tests := []struct {
+		level float64
+		want  string
+	}{
+		{10, LevelTrace},
+		{0, LevelTrace},
+		{20, LevelDebug},
+		{30, LevelInfo},
+		{40, LevelWarning},
+		{50, LevelError},
+		{55, LevelError},
+		{60, LevelFatal},
+		{100, LevelFatal},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, numericLevel(tt.level), "level %v", tt.level)
+	}
+}
+
+func TestParseLogfmt(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   map[string]string
+		wantOK bool
+	}{
+		{"pairs", `a=1 b=two`, map[string]string{"a": "1", "b": "two"}, true},
+		{"quoted value", `msg="hello world" a=1`, map[string]string{"msg": "hello world", "a": "1"}, true},
+		{"escaped quote", `msg="say \"hi\""`, map[string]string{"msg": `say "hi"`}, true},
+		{"escaped backslash", `path="C:\\tmp"`, map[string]string{"path": `C:\tmp`}, true},
+		{"empty value", `a= b=1`, map[string]string{"a": "", "b": "1"}, true},
+		{"empty quoted value", `a="" b=1`, map[string]string{"a": "", "b": "1"}, true},
+		{"key without value", `flag a=1`, map[string]string{"flag": "", "a": "1"}, true},
+		{"unterminated quote", `msg="open a=1`, map[string]string{"msg": "open a=1"}, true},
+		{"value with equals", `q=a=b`, map[string]string{"q": "a=b"}, true},
+		{"leading equals", `=x a=1`, map[string]string{"x": "", "a": "1"}, true},
+		{"surrounding space", "  a=1\t", map[string]string{"a": "1"}, true},
+		{"last key wins", `a=1 a=2`, map[string]string{"a": "2"}, true},
+		{"empty", ``, map[string]string{}, false},
+		{"only space", `   `, map[string]string{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, ok := parseLogfmt(tt.line)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, fields)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output streams of the app.
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// maxLogLineLength is the longest line the log monitors can read. Longer lines
+// would stop the monitor and block the app writing to the pipe.
+const maxLogLineLength = 1024 * 1024
+
+// LogLine is a single line written by the app.
+type LogLine struct {
+	Time   time.Time
+	Stream string
+	Text   string
+	// Level and Message are extracted from Text by the log parser. Level is
+	// empty if the line is not in a known format.
+	Level   string
+	Message string
+}
+
+// LogBuffer keeps all lines written by the app in the order they were read.
+// It is safe for concurrent use.
+type LogBuffer struct {
+	mu      sync.Mutex
+	lines   []LogLine
+	changed chan struct{}
+}
+
+func newLogBuffer() *LogBuffer {
+	return &LogBuffer{changed: make(chan struct{})}
+}
+
+// Append adds a line to the buffer and wakes up everyone waiting for it. If
+// the time of the line is not set, the current time is used.
+func (b *LogBuffer) Append(line LogLine) {
+	if line.Time.IsZero() {
+		line.Time = time.Now()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.lines = append(b.lines, line)
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// Lines returns a copy of all lines in the buffer.
+func (b *LogBuffer) Lines() []LogLine {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]LogLine(nil), b.lines...)
+}
+
+// Contains reports whether any line contains the substring.
+func (b *LogBuffer) Contains(substr string) bool {
+	for _, line := range b.Lines() {
+		if strings.Contains(line.Text, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matching returns all lines matching the regular expression.
+func (b *LogBuffer) Matching(re *regexp.Regexp) []LogLine {
+	var matching []LogLine
+	for _, line := range b.Lines() {
+		if re.MatchString(line.Text) {
+			matching = append(matching, line)
+		}
+	}
+	return matching
+}
+
+// WaitFor blocks until a line matching the regular expression is in the buffer
+// or the context is done. Lines read before the call are considered as well.
+func (b *LogBuffer) WaitFor(ctx context.Context, re *regexp.Regexp) (LogLine, error) {
+	checked := 0
+	for {
+		b.mu.Lock()
+		lines := b.lines[checked:]
+		changed := b.changed
+		b.mu.Unlock()
+
+		for _, line := range lines {
+			if re.MatchString(line.Text) {
+				return line, nil
+			}
+		}
+		checked += len(lines)
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return LogLine{}, ctx.Err()
+		}
+	}
+}
+
+// Logs returns the lines written by the app started by RunApp or StartApp.
+func Logs() *LogBuffer {
+	if defaultHarness == nil {
+		return newLogBuffer()
+	}
+	return defaultHarness.Logs()
+}
+
+// Logs returns the lines written by the app since the harness was created.
+func (h *Harness) Logs() *LogBuffer {
+	return h.logs
+}
+
+// monitorOutput reads the output of the app line by line until the stream is
+// closed. Each line is passed to handle.
+func (h *Harness) monitorOutput(pipe io.Reader, handle func(line string)) {
+	scanner := bufio.NewScanner(pipe)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLogLineLength)
+	for scanner.Scan() {
+		handle(scanner.Text())
+	}
+}
+
+// handleLogLine parses and stores the line and records it if it reports an error.
+func (h *Harness) handleLogLine(stream, line string) {
+	entry, _ := h.logParser.Parse(line)
+	h.logs.Append(LogLine{
+		Stream:  stream,
+		Text:    line,
+		Level:   entry.Level,
+		Message: entry.Message,
+	})
+	if entry.IsError() {
+		h.recordLogError(line)
+	}
+	if line == raceReportHeader {
+		// Races are never allowed.
+		h.appendLogError(line)
+	}
+	if h.restrictRole && isPermissionError(line) {
+		// Missing privileges of the restricted role are never allowed, whatever
+		// the level they are logged with.
+		h.appendLogError(line)
+	}
+}
@@ -0,0 +1,103 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogBufferWaitFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  []string
+		after   []string
+		pattern string
+		want    string
+		wantErr error
+	}{
+		{"line appended later", []string{"starting"}, []string{"connecting", "sync of 3 assets finished"}, `sync of \d+ assets`, "sync of 3 assets finished", nil},
+		{"line read before the call", []string{"sync of 3 assets finished"}, nil, `sync of \d+ assets`, "sync of 3 assets finished", nil},
+		{"first matching line", nil, []string{"sync 1 finished", "sync 2 finished"}, `sync \d finished`, "sync 1 finished", nil},
+		{"no matching line", []string{"starting"}, []string{"connecting", "retrying"}, `finished`, "", context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := newLogBuffer()
+			for _, text := range tt.before {
+				logs.Append(LogLine{Text: text})
+			}
+			go func() {
+				for _, text := range tt.after {
+					time.Sleep(10 * time.Millisecond)
+					logs.Append(LogLine{Text: text})
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			line, err := logs.WaitFor(ctx, regexp.MustCompile(tt.pattern))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, line.Text)
+			assert.False(t, line.Time.IsZero(), "time of the line is set")
+		})
+	}
+}
+
+func TestLogBufferWaitForCanceled(t *testing.T) {
+	logs := newLogBuffer()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	_, err := logs.WaitFor(ctx, regexp.MustCompile(`ready`))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLogBufferQueries(t *testing.T) {
+	logs := newLogBuffer()
+	for _, text := range []string{"INFO\tstarted", "ERROR\tsync failed", "INFO\tsync finished"} {
+		logs.Append(LogLine{Stream: StreamStderr, Text: text})
+	}
+
+	assert.Len(t, logs.Lines(), 3)
+	assert.True(t, logs.Contains("sync failed"))
+	assert.False(t, logs.Contains("stopped"))
+
+	var matching []string
+	for _, line := range logs.Matching(regexp.MustCompile(`^\w+\tsync`)) {
+		matching = append(matching, line.Text)
+	}
+	assert.Equal(t, []string{"ERROR\tsync failed", "INFO\tsync finished"}, matching)
+}
+
+func TestMonitorOutput(t *testing.T) {
+	h := &Harness{}
+	var lines []string
+	h.monitorOutput(strings.NewReader("first\nsecond\r\n\nlast"), func(line string) { lines = append(lines, line) })
+	assert.Equal(t, []string{"first", "second", "", "last"}, lines)
+}
@@ -0,0 +1,178 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/eliona-smart-building-assistant/go-utils/db"
+)
+
+// resetState is the part of the database a reset script has to restore. Each
+// field lists the keys of the rows or objects present in the database.
+type resetState struct {
+	schemas          []string
+	assetTypes       []string
+	attributeSchemas []string
+	widgetTypes      []string
+	storeApps        []string
+	patches          []string
+}
+
+// resetStateQueries are the queries collecting the fields of resetState. The
+// queries of missing tables are skipped.
+var resetStateQueries = []struct {
+	name  string
+	table string
+	query string
+	field func(*resetState) *[]string
+}{
+	{"schemas", "", `
+		SELECT nspname
+		FROM pg_namespace
+		WHERE nspname NOT LIKE 'pg\_%' AND nspname <> 'information_schema' AND nspname NOT LIKE 'integration\_test\_%'`,
+		func(s *resetState) *[]string { return &s.schemas }},
+	{"asset types", "public.asset_type", `
+		SELECT asset_type FROM public.asset_type`,
+		func(s *resetState) *[]string { return &s.assetTypes }},
+	{"attribute schemas", "public.attribute_schema", `
+		SELECT concat_ws('/', asset_type, subtype, attribute) FROM public.attribute_schema`,
+		func(s *resetState) *[]string { return &s.attributeSchemas }},
+	{"widget types", "public.widget_type", `
+		SELECT name FROM public.widget_type`,
+		func(s *resetState) *[]string { return &s.widgetTypes }},
+	{"store rows", "public.eliona_store", `
+		SELECT app_name FROM public.eliona_store`,
+		func(s *resetState) *[]string { return &s.storeApps }},
+	{"patches", "versioning.patches", `
+		SELECT concat_ws('/', app_name, patch_name) FROM versioning.patches`,
+		func(s *resetState) *[]string { return &s.patches }},
+}
+
+// VerifyReset checks that reset.sql is idempotent and complete: the script
+// has to run twice without errors and leave no schemas, asset types,
+// attribute schemas, widget types, store rows or patches behind that were not
+// present before the app was started the first time. The app has to be
+// stopped, since it would recreate them.
+func (h *Harness) VerifyReset(ctx context.Context) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.running {
+		return errors.New("app has to be stopped to verify the reset script")
+	}
+	if h.resetBaseline == nil {
+		return errors.New("app was not started")
+	}
+
+	sqlScript, err := os.ReadFile("reset.sql")
+	if err != nil {
+		return fmt.Errorf("reading reset SQL script: %w", err)
+	}
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+	for run := 1; run <= 2; run++ {
+		if err := runResetScript(database, h.metadata, string(sqlScript)); err != nil {
+			return fmt.Errorf("run %d: %w", run, err)
+		}
+	}
+
+	state, err := queryResetState(ctx, database)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, query := range resetStateQueries {
+		baseline, current := *query.field(h.resetBaseline), *query.field(state)
+		var leftovers []string
+		for _, key := range current {
+			if !slices.Contains(baseline, key) {
+				leftovers = append(leftovers, key)
+			}
+		}
+		if len(leftovers) > 0 {
+			errs = append(errs, fmt.Errorf("reset script leaves %s behind: %s", query.name, strings.Join(leftovers, ", ")))
+		}
+	}
+	// An app schema surviving the reset before the first start is not a leftover
+	// compared to the baseline, but still has to be dropped.
+	for _, schema := range state.schemas {
+		if slices.Contains(h.appSchemas(), schema) && slices.Contains(h.resetBaseline.schemas, schema) {
+			errs = append(errs, fmt.Errorf("reset script does not drop the app schema %s", schema))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// takeResetBaseline records the state of the database before the app is
+// started the first time.
+func (h *Harness) takeResetBaseline(ctx context.Context) error {
+	if h.resetBaseline != nil {
+		return nil
+	}
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+	state, err := queryResetState(ctx, database)
+	if err != nil {
+		return err
+	}
+	h.resetBaseline = state
+	return nil
+}
+
+func queryResetState(ctx context.Context, database *sql.DB) (*resetState, error) {
+	state := &resetState{}
+	for _, query := range resetStateQueries {
+		if query.table != "" {
+			var exists bool
+			if err := database.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, query.table).Scan(&exists); err != nil {
+				return nil, fmt.Errorf("checking table %s: %w", query.table, err)
+			}
+			if !exists {
+				continue
+			}
+		}
+		keys, err := queryStrings(ctx, database, query.query)
+		if err != nil {
+			return nil, fmt.Errorf("querying %s: %w", query.name, err)
+		}
+		*query.field(state) = keys
+	}
+	return state, nil
+}
+
+func queryStrings(ctx context.Context, database *sql.DB, query string, args ...any) ([]string, error) {
+	rows, err := database.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
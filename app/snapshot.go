@@ -0,0 +1,297 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eliona-smart-building-assistant/go-utils/db"
+	"github.com/lib/pq"
+)
+
+// The snapshot is a copy of the tables of the app schema and of the core tables
+// apps write to through the Eliona API. It is kept in a schema of its own,
+// because the database cannot be copied as a template while the app is
+// connected to it. Only the data is restored, not the structure of the tables.
+
+// snapshotCoreTables are the core tables included in the snapshot, if they exist.
+var snapshotCoreTables = []string{
+	"public.eliona_store",
+	"public.eliona_app",
+	"versioning.patches",
+	"public.asset_type",
+	"public.attribute_schema",
+	"public.asset",
+	"public.heap",
+	"public.widget_type",
+	"public.widget_type_element",
+	"public.dashboard",
+	"public.widget",
+	"public.widget_data",
+}
+
+type snapshotTable struct {
+	// name is the qualified and quoted name of the table.
+	name    string
+	columns []string
+}
+
+// RestoreSnapshot restores the database state taken after the app was
+// initialized by the harness started by RunApp or StartApp.
+func RestoreSnapshot(t *testing.T) {
+	t.Helper()
+	if defaultHarness == nil {
+		t.Fatal("restoring snapshot: app is not started")
+	}
+	defaultHarness.RestoreSnapshot(t)
+}
+
+// RestoreSnapshot restores the database state taken after the app was
+// initialized, so that tests do not depend on changes made by earlier tests.
+// The app keeps running. It requires WithSnapshot.
+func (h *Harness) RestoreSnapshot(t *testing.T) {
+	t.Helper()
+	if err := h.restoreSnapshot(context.Background()); err != nil {
+		t.Fatalf("restoring snapshot: %v", err)
+	}
+}
+
+// waitForAppInitialized waits until the app registered itself as initialized.
+func (h *Harness) waitForAppInitialized(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, h.readyTimeout)
+	defer cancel()
+
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+	for {
+		var initialized *time.Time
+		err := database.QueryRowContext(ctx, `
+			SELECT initialized_at
+			FROM public.eliona_app
+			WHERE app_name = $1`, h.metadata.Name).Scan(&initialized)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("checking if app is initialized: %w", err)
+		}
+		if initialized != nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return errors.New("app was not initialized in the specified time")
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// takeSnapshot copies the tables into the snapshot schema, replacing a
+// snapshot taken before.
+func (h *Harness) takeSnapshot(ctx context.Context) error {
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+
+	tables, err := snapshotTables(ctx, database, h.appSchemas())
+	if err != nil {
+		return err
+	}
+	schema := pq.QuoteIdentifier(h.snapshotSchema())
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		DROP SCHEMA IF EXISTS %[1]s CASCADE;
+		CREATE SCHEMA %[1]s;
+		CREATE TABLE %[1]s.sequences (name text, last_value bigint, is_called boolean);`, schema)); err != nil {
+		return fmt.Errorf("creating snapshot schema: %w", err)
+	}
+	for _, table := range tables {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`CREATE TABLE %s.%s AS SELECT * FROM %s`,
+			schema, pq.QuoteIdentifier(table.name), table.name)); err != nil {
+			return fmt.Errorf("copying table %s: %w", table.name, err)
+		}
+	}
+
+	sequences, err := snapshotSequences(ctx, tx, tables)
+	if err != nil {
+		return err
+	}
+	for _, sequence := range sequences {
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			INSERT INTO %s.sequences (name, last_value, is_called)
+			SELECT $1, last_value, is_called FROM %s`, schema, sequence), sequence); err != nil {
+			return fmt.Errorf("copying sequence %s: %w", sequence, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.snapshotted = tables
+	return nil
+}
+
+// restoreSnapshot replaces the content of the tables by the snapshot. Triggers
+// and foreign keys are disabled while restoring, so that the order of the
+// tables does not matter.
+func (h *Harness) restoreSnapshot(ctx context.Context) error {
+	h.mu.Lock()
+	tables := h.snapshotted
+	h.mu.Unlock()
+	if tables == nil {
+		return errors.New("no snapshot taken, start the app with the snapshot option")
+	}
+	schema := pq.QuoteIdentifier(h.snapshotSchema())
+
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		SET LOCAL session_replication_role = replica;
+		SET LOCAL lock_timeout = '10s';`); err != nil {
+		return fmt.Errorf("disabling triggers: %w", err)
+	}
+	for _, table := range tables {
+		columns := strings.Join(table.columns, ", ")
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+			DELETE FROM %[1]s;
+			INSERT INTO %[1]s (%[2]s) OVERRIDING SYSTEM VALUE SELECT %[2]s FROM %[3]s.%[4]s;`,
+			table.name, columns, schema, pq.QuoteIdentifier(table.name))); err != nil {
+			return fmt.Errorf("restoring table %s: %w", table.name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`
+		SELECT setval(name::regclass, last_value, is_called) FROM %s.sequences`, schema)); err != nil {
+		return fmt.Errorf("restoring sequences: %w", err)
+	}
+	return tx.Commit()
+}
+
+// dropSnapshot removes the snapshot schema.
+func (h *Harness) dropSnapshot() error {
+	if h.snapshotted == nil {
+		return nil
+	}
+	database := db.NewInitDatabase("integration_test")
+	defer database.Close()
+	if _, err := database.Exec(fmt.Sprintf(`DROP SCHEMA IF EXISTS %s CASCADE`, pq.QuoteIdentifier(h.snapshotSchema()))); err != nil {
+		return fmt.Errorf("dropping snapshot: %w", err)
+	}
+	h.snapshotted = nil
+	return nil
+}
+
+func (h *Harness) snapshotSchema() string {
+	return "integration_test_snapshot_" + h.runID
+}
+
+// snapshotTables returns the tables of the app schemas and the existing core
+// tables.
+func snapshotTables(ctx context.Context, database *sql.DB, appSchemas []string) ([]snapshotTable, error) {
+	rows, err := database.QueryContext(ctx, `
+		SELECT format('%I.%I', table_schema, table_name)
+		FROM information_schema.tables
+		WHERE table_type = 'BASE TABLE' AND table_schema = ANY ($1::text[])
+		UNION ALL
+		SELECT to_regclass(core)::text
+		FROM unnest($2::text[]) AS core
+		WHERE to_regclass(core) IS NOT NULL`, pq.Array(appSchemas), pq.Array(snapshotCoreTables))
+	if err != nil {
+		return nil, fmt.Errorf("listing tables: %w", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tables := make([]snapshotTable, len(names))
+	for i, name := range names {
+		tables[i].name = name
+		// Generated columns cannot be restored, they are computed again.
+		rows, err := database.QueryContext(ctx, `
+			SELECT quote_ident(attname)
+			FROM pg_attribute
+			WHERE attrelid = $1::regclass AND attnum > 0 AND NOT attisdropped AND attgenerated = ''
+			ORDER BY attnum`, name)
+		if err != nil {
+			return nil, fmt.Errorf("listing columns of %s: %w", name, err)
+		}
+		for rows.Next() {
+			var column string
+			if err := rows.Scan(&column); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			tables[i].columns = append(tables[i].columns, column)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// snapshotSequences returns the sequences owned by columns of the tables, like
+// the sequences of serial columns.
+func snapshotSequences(ctx context.Context, tx *sql.Tx, tables []snapshotTable) ([]string, error) {
+	names := make([]string, len(tables))
+	for i, table := range tables {
+		names[i] = table.name
+	}
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT sequence.oid::regclass::text
+		FROM pg_class sequence
+			JOIN pg_depend dependency ON dependency.objid = sequence.oid AND dependency.deptype IN ('a', 'i')
+		WHERE sequence.relkind = 'S' AND dependency.refobjid = ANY (
+			SELECT to_regclass(name) FROM unnest($1::text[]) AS name
+		)`, pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("listing sequences: %w", err)
+	}
+	defer rows.Close()
+	var sequences []string
+	for rows.Next() {
+		var sequence string
+		if err := rows.Scan(&sequence); err != nil {
+			return nil, err
+		}
+		sequences = append(sequences, sequence)
+	}
+	return sequences, rows.Err()
+}
@@ -0,0 +1,83 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package assert
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
+	"github.com/stretchr/testify/assert"
+)
+
+// The path patterns of the API assertions are matched as by path.Match, so "*"
+// matches a single path segment, e.g. "/v2/asset-types/*/attributes".
+
+func APICalled(t *testing.T, method string, pattern string, msgAndArgs ...any) bool {
+	if len(app.APICalls().Matching(method, pattern)) == 0 {
+		return assert.Fail(t, fmt.Sprintf("App didn't call %s %s\n%s", method, pattern, formatAPICalls(app.APICalls().Calls())), msgAndArgs...)
+	}
+	return true
+}
+
+func APINotCalled(t *testing.T, method string, pattern string, msgAndArgs ...any) bool {
+	matching := app.APICalls().Matching(method, pattern)
+	if len(matching) > 0 {
+		return assert.Fail(t, fmt.Sprintf("App called %s %s\n%s", method, pattern, formatAPICalls(matching)), msgAndArgs...)
+	}
+	return true
+}
+
+func APICallCount(t *testing.T, method string, pattern string, expected int, msgAndArgs ...any) bool {
+	matching := app.APICalls().Matching(method, pattern)
+	if len(matching) != expected {
+		return assert.Fail(t, fmt.Sprintf("App called %s %s %d times, expected %d\n%s", method, pattern, len(matching), expected, formatAPICalls(matching)), msgAndArgs...)
+	}
+	return true
+}
+
+// APICalledInOrder checks that the app made the calls in the given order, each
+// given as method and path pattern, e.g. "PUT /v2/asset-types". Other calls may
+// happen in between.
+func APICalledInOrder(t *testing.T, expected []string, msgAndArgs ...any) bool {
+	calls := app.APICalls().Calls()
+	next := 0
+	for _, call := range calls {
+		if next == len(expected) {
+			break
+		}
+		method, pattern, _ := strings.Cut(expected[next], " ")
+		if call.Matches(method, pattern) {
+			next++
+		}
+	}
+	if next < len(expected) {
+		return assert.Fail(t, fmt.Sprintf("App didn't call %q in order, missing from %q\n%s", expected, expected[next], formatAPICalls(calls)), msgAndArgs...)
+	}
+	return true
+}
+
+func formatAPICalls(calls []app.APICall) string {
+	if len(calls) == 0 {
+		return "No calls were recorded"
+	}
+	lines := make([]string, len(calls))
+	for i, call := range calls {
+		lines[i] = call.String()
+	}
+	return "Recorded calls:\n" + strings.Join(lines, "\n")
+}
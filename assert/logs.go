@@ -0,0 +1,63 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package assert
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func LogContains(t *testing.T, expected string, msgAndArgs ...any) bool {
+	if !app.Logs().Contains(expected) {
+		return assert.Fail(t, fmt.Sprintf("App log doesn't contain %q", expected), msgAndArgs...)
+	}
+	return true
+}
+
+func EventuallyLogs(t *testing.T, pattern string, timeout time.Duration, msgAndArgs ...any) bool {
+	re, err := regexp.Compile(pattern)
+	require.NoError(t, err, msgAndArgs...)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if _, err := app.Logs().WaitFor(ctx, re); err != nil {
+		return assert.Fail(t, fmt.Sprintf("App didn't log a line matching %q within %s", pattern, timeout), msgAndArgs...)
+	}
+	return true
+}
+
+func NoLogsMatching(t *testing.T, pattern string, msgAndArgs ...any) bool {
+	re, err := regexp.Compile(pattern)
+	require.NoError(t, err, msgAndArgs...)
+
+	matching := app.Logs().Matching(re)
+	if len(matching) > 0 {
+		lines := make([]string, len(matching))
+		for i, line := range matching {
+			lines[i] = line.Text
+		}
+		return assert.Fail(t, fmt.Sprintf("App logged lines matching %q:\n%s", pattern, strings.Join(lines, "\n")), msgAndArgs...)
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	api "github.com/eliona-smart-building-assistant/go-eliona-api-client/v2"
+)
+
+// getApp returns the app. It is registered once initialized_at is set, which
+// is what the reset script of every app clears.
+func (s *Server) getApp(w http.ResponseWriter, r *http.Request) {
+	app, err := s.queryApp(r, r.PathValue("app"))
+	writeResult(w, app, err)
+}
+
+func (s *Server) patchApp(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("app")
+	if value := r.URL.Query().Get("registered"); value != "" {
+		registered, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("parsing registered: %w", err))
+			return
+		}
+		var initializedAt *time.Time
+		if registered {
+			now := time.Now()
+			initializedAt = &now
+		}
+		result, err := s.db.ExecContext(r.Context(), `
+			UPDATE public.eliona_app
+			SET initialized_at = $1
+			WHERE app_name = $2`, initializedAt, name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			writeResult(w, nil, sql.ErrNoRows)
+			return
+		}
+	}
+	app, err := s.queryApp(r, name)
+	writeResult(w, app, err)
+}
+
+func (s *Server) queryApp(r *http.Request, name string) (*api.App, error) {
+	var enable sql.NullBool
+	var initializedAt *time.Time
+	err := s.db.QueryRowContext(r.Context(), `
+		SELECT enable, initialized_at
+		FROM public.eliona_app
+		WHERE app_name = $1`, name).Scan(&enable, &initializedAt)
+	if err != nil {
+		return nil, err
+	}
+	app := api.NewApp(name)
+	if enable.Valid {
+		app.SetActive(enable.Bool)
+	}
+	app.SetRegistered(initializedAt != nil)
+	return app, nil
+}
+
+// getPatch returns the patch of the app. Patches not known yet are reported
+// as not applied.
+func (s *Server) getPatch(w http.ResponseWriter, r *http.Request) {
+	patch, err := s.queryPatch(r, r.PathValue("app"), r.PathValue("patch"))
+	writeResult(w, patch, err)
+}
+
+func (s *Server) patchPatch(w http.ResponseWriter, r *http.Request) {
+	appName, patchName := r.PathValue("app"), r.PathValue("patch")
+	if value := r.URL.Query().Get("apply"); value != "" {
+		apply, err := strconv.ParseBool(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("parsing apply: %w", err))
+			return
+		}
+		if apply {
+			err = s.withTx(r.Context(), func(tx *sql.Tx) error {
+				result, err := tx.ExecContext(r.Context(), `
+					UPDATE versioning.patches
+					SET applied_at = now()
+					WHERE app_name = $1 AND patch_name = $2`, appName, patchName)
+				if err != nil {
+					return err
+				}
+				if n, _ := result.RowsAffected(); n > 0 {
+					return nil
+				}
+				_, err = tx.ExecContext(r.Context(), `
+					INSERT INTO versioning.patches (app_name, patch_name, applied_at)
+					VALUES ($1, $2, now())`, appName, patchName)
+				return err
+			})
+		} else {
+			_, err = s.db.ExecContext(r.Context(), `
+				UPDATE versioning.patches
+				SET applied_at = NULL
+				WHERE app_name = $1 AND patch_name = $2`, appName, patchName)
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	patch, err := s.queryPatch(r, appName, patchName)
+	writeResult(w, patch, err)
+}
+
+func (s *Server) queryPatch(r *http.Request, appName, patchName string) (*api.Patch, error) {
+	var appliedAt *time.Time
+	err := s.db.QueryRowContext(r.Context(), `
+		SELECT applied_at
+		FROM versioning.patches
+		WHERE app_name = $1 AND patch_name = $2`, appName, patchName).Scan(&appliedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	patch := api.NewPatch(appName, patchName)
+	patch.SetApplied(appliedAt != nil)
+	return patch, nil
+}
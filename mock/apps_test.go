@@ -0,0 +1,152 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"testing"
+	"time"
+
+	api "github.com/eliona-smart-building-assistant/go-eliona-api-client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var appColumns = []string{"enable", "initialized_at"}
+
+func TestGetApp(t *testing.T) {
+	tests := []struct {
+		name           string
+		rows           [][]driver.Value
+		want           int
+		wantActive     *bool
+		wantRegistered bool
+	}{
+		{"registered", [][]driver.Value{{true, time.Now()}}, http.StatusOK, api.PtrBool(true), true},
+		{"reset", [][]driver.Value{{false, nil}}, http.StatusOK, api.PtrBool(false), false},
+		{"not enabled yet", [][]driver.Value{{nil, nil}}, http.StatusOK, nil, false},
+		{"unknown", nil, http.StatusNotFound, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := newFakeDB(t, fakeResult{match: "FROM public.eliona_app", columns: appColumns, rows: tt.rows})
+			response := serve(New(db, ""), http.MethodGet, BasePath+"/apps/my-app", "")
+
+			require.Equal(t, tt.want, response.Code, "body %s", response.Body)
+			assert.Equal(t, []any{"my-app"}, f.args(0))
+			if tt.want != http.StatusOK {
+				return
+			}
+			var app api.App
+			decode(t, response, &app)
+			assert.Equal(t, "my-app", app.Name)
+			assert.Equal(t, tt.wantActive, app.Active.Get())
+			assert.Equal(t, tt.wantRegistered, app.GetRegistered())
+		})
+	}
+}
+
+func TestPatchAppRegistered(t *testing.T) {
+	t.Run("register", func(t *testing.T) {
+		db, f := newFakeDB(t,
+			fakeResult{match: "UPDATE public.eliona_app SET initialized_at = $1", affected: 1},
+			fakeResult{match: "FROM public.eliona_app", columns: appColumns, rows: [][]driver.Value{{true, time.Now()}}},
+		)
+		response := serve(New(db, ""), http.MethodPatch, BasePath+"/apps/my-app?registered=true", "")
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		assert.IsType(t, time.Time{}, f.args(0)[0])
+		assert.Equal(t, "my-app", f.args(0)[1])
+		var app api.App
+		decode(t, response, &app)
+		assert.True(t, app.GetRegistered())
+	})
+
+	t.Run("unregister", func(t *testing.T) {
+		db, f := newFakeDB(t,
+			fakeResult{match: "UPDATE public.eliona_app", affected: 1},
+			fakeResult{match: "FROM public.eliona_app", columns: appColumns, rows: [][]driver.Value{{true, nil}}},
+		)
+		response := serve(New(db, ""), http.MethodPatch, BasePath+"/apps/my-app?registered=false", "")
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		assert.Nil(t, f.args(0)[0])
+		var app api.App
+		decode(t, response, &app)
+		assert.False(t, app.GetRegistered())
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		db, _ := newFakeDB(t, fakeResult{match: "UPDATE public.eliona_app", affected: 0})
+		response := serve(New(db, ""), http.MethodPatch, BasePath+"/apps/my-app?registered=true", "")
+
+		assert.Equal(t, http.StatusNotFound, response.Code)
+	})
+}
+
+func TestGetPatch(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]driver.Value
+		want bool
+	}{
+		{"applied", [][]driver.Value{{time.Now()}}, true},
+		{"not applied", [][]driver.Value{{nil}}, false},
+		{"unknown", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := newFakeDB(t, fakeResult{match: "FROM versioning.patches", columns: []string{"applied_at"}, rows: tt.rows})
+			response := serve(New(db, ""), http.MethodGet, BasePath+"/apps/my-app/patches/v1.1.0", "")
+
+			require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+			assert.Equal(t, []any{"my-app", "v1.1.0"}, f.args(0))
+			var patch api.Patch
+			decode(t, response, &patch)
+			assert.Equal(t, "v1.1.0", patch.Name)
+			assert.Equal(t, tt.want, patch.GetApplied())
+		})
+	}
+}
+
+func TestPatchPatchApply(t *testing.T) {
+	tests := []struct {
+		name     string
+		affected int64
+		want     []string
+	}{
+		{"known patch", 1, []string{"UPDATE", "COMMIT", "SELECT"}},
+		{"new patch", 0, []string{"UPDATE", "INSERT", "COMMIT", "SELECT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := []fakeResult{{match: "UPDATE versioning.patches SET applied_at = now()", affected: tt.affected}}
+			if tt.affected == 0 {
+				results = append(results, fakeResult{match: "INSERT INTO versioning.patches", affected: 1})
+			}
+			results = append(results, fakeResult{match: "FROM versioning.patches", columns: []string{"applied_at"}, rows: [][]driver.Value{{time.Now()}}})
+			db, f := newFakeDB(t, results...)
+			response := serve(New(db, ""), http.MethodPatch, BasePath+"/apps/my-app/patches/v1.1.0?apply=true", "")
+
+			require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+			assert.Equal(t, tt.want, statementKinds(f.queries()))
+			var patch api.Patch
+			decode(t, response, &patch)
+			assert.True(t, patch.GetApplied())
+		})
+	}
+}
@@ -0,0 +1,424 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	api "github.com/eliona-smart-building-assistant/go-eliona-api-client/v2"
+	"github.com/lib/pq"
+)
+
+// Upserts are done by an update followed by an insert if nothing was updated,
+// so that the mock does not depend on unique constraints of the tables.
+
+func (s *Server) getAssetTypes(w http.ResponseWriter, r *http.Request) {
+	assetTypes, err := queryAssetTypes(r.Context(), s.db, "", expansions(r, "AssetType.attributes"))
+	writeResult(w, assetTypes, err)
+}
+
+func (s *Server) getAssetType(w http.ResponseWriter, r *http.Request) {
+	assetTypes, err := queryAssetTypes(r.Context(), s.db, r.PathValue("type"), expansions(r, "AssetType.attributes"))
+	if err == nil && len(assetTypes) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		writeResult(w, nil, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, assetTypes[0])
+}
+
+// putAssetType upserts the asset type. Its attributes are upserted as well if
+// the AssetType.attributes expansion is requested, as done by go-eliona.
+func (s *Server) putAssetType(w http.ResponseWriter, r *http.Request) {
+	var assetType api.AssetType
+	if !readJSON(w, r, &assetType) {
+		return
+	}
+	withAttributes := expansions(r, "AssetType.attributes")
+
+	var assetTypes []api.AssetType
+	err := s.withTx(r.Context(), func(tx *sql.Tx) error {
+		if err := upsertAssetType(r.Context(), tx, assetType); err != nil {
+			return err
+		}
+		if withAttributes {
+			for _, attribute := range assetType.Attributes {
+				if err := upsertAttribute(r.Context(), tx, assetType.Name, attribute); err != nil {
+					return err
+				}
+			}
+		}
+		var err error
+		assetTypes, err = queryAssetTypes(r.Context(), tx, assetType.Name, withAttributes)
+		return err
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, assetTypes[0])
+}
+
+func (s *Server) putAssetTypeAttribute(w http.ResponseWriter, r *http.Request) {
+	var attribute api.AssetTypeAttribute
+	if !readJSON(w, r, &attribute) {
+		return
+	}
+	assetTypeName := r.PathValue("type")
+	if err := upsertAttribute(r.Context(), s.db, assetTypeName, attribute); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	attribute.SetAssetTypeName(assetTypeName)
+	writeJSON(w, http.StatusOK, attribute)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func upsertAssetType(ctx context.Context, q querier, assetType api.AssetType) error {
+	translation, err := nullJSON(assetType.Translation.Get())
+	if err != nil {
+		return err
+	}
+	custom := assetType.Custom == nil || *assetType.Custom
+	args := []any{assetType.Name, custom, deref(assetType.Vendor.Get()), deref(assetType.Model.Get()),
+		translation, deref(assetType.Urldoc.Get()), deref(assetType.Icon.Get())}
+	result, err := q.ExecContext(ctx, `
+		UPDATE public.asset_type
+		SET custom = $2, vendor = $3, model = $4, translation = $5, urldoc = $6, icon = $7
+		WHERE asset_type = $1`, args...)
+	if err != nil {
+		return fmt.Errorf("updating asset type %s: %w", assetType.Name, err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	if _, err := q.ExecContext(ctx, `
+		INSERT INTO public.asset_type (asset_type, custom, vendor, model, translation, urldoc, icon)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`, args...); err != nil {
+		return fmt.Errorf("inserting asset type %s: %w", assetType.Name, err)
+	}
+	return nil
+}
+
+func upsertAttribute(ctx context.Context, q querier, assetTypeName string, attribute api.AssetTypeAttribute) error {
+	translation, err := nullJSON(attribute.Translation.Get())
+	if err != nil {
+		return err
+	}
+	enable := attribute.Enable == nil || *attribute.Enable
+	args := []any{assetTypeName, string(attribute.Subtype), attribute.Name, deref(attribute.Type.Get()), enable,
+		translation, deref(attribute.Unit.Get()), deref(attribute.Precision.Get()), deref(attribute.Min.Get()),
+		deref(attribute.Max.Get()), deref(attribute.Viewer.Get()), deref(attribute.Ar.Get()),
+		deref(attribute.Sequence.Get()), deref(attribute.Virtual.Get())}
+	result, err := q.ExecContext(ctx, `
+		UPDATE public.attribute_schema
+		SET attribute_type = $4, enable = $5, translation = $6, unit = $7, precision = $8, min = $9, max = $10,
+			viewer = $11, ar = $12, seq = $13, virtual = $14
+		WHERE asset_type = $1 AND subtype = $2 AND attribute = $3`, args...)
+	if err != nil {
+		return fmt.Errorf("updating attribute %s of asset type %s: %w", attribute.Name, assetTypeName, err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	if _, err := q.ExecContext(ctx, `
+		INSERT INTO public.attribute_schema (asset_type, subtype, attribute, attribute_type, enable, translation,
+			unit, precision, min, max, viewer, ar, seq, virtual)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`, args...); err != nil {
+		return fmt.Errorf("inserting attribute %s of asset type %s: %w", attribute.Name, assetTypeName, err)
+	}
+	return nil
+}
+
+// queryAssetTypes returns the asset type with the given name, or all asset
+// types if the name is empty.
+func queryAssetTypes(ctx context.Context, q querier, name string, withAttributes bool) ([]api.AssetType, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT asset_type, custom, vendor, model, translation, urldoc, icon
+		FROM public.asset_type
+		WHERE $1 = '' OR asset_type = $1
+		ORDER BY asset_type`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assetTypes := []api.AssetType{}
+	for rows.Next() {
+		var custom sql.Null[bool]
+		var vendor, model, urldoc, icon sql.Null[string]
+		var translation []byte
+		assetType := api.NewAssetType("")
+		if err := rows.Scan(&assetType.Name, &custom, &vendor, &model, &translation, &urldoc, &icon); err != nil {
+			return nil, err
+		}
+		assetType.Custom = ptr(custom)
+		assetType.Vendor = *api.NewNullableString(ptr(vendor))
+		assetType.Model = *api.NewNullableString(ptr(model))
+		assetType.Urldoc = *api.NewNullableString(ptr(urldoc))
+		assetType.Icon = *api.NewNullableString(ptr(icon))
+		if assetType.Translation, err = parseTranslation(translation); err != nil {
+			return nil, err
+		}
+		assetTypes = append(assetTypes, *assetType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if withAttributes {
+		for i := range assetTypes {
+			if assetTypes[i].Attributes, err = queryAttributes(ctx, q, assetTypes[i].Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return assetTypes, nil
+}
+
+func queryAttributes(ctx context.Context, q querier, assetTypeName string) ([]api.AssetTypeAttribute, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT attribute, subtype, attribute_type, enable, translation, unit, precision, min, max, viewer, ar, seq, virtual
+		FROM public.attribute_schema
+		WHERE asset_type = $1
+		ORDER BY seq, attribute`, assetTypeName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributes := []api.AssetTypeAttribute{}
+	for rows.Next() {
+		var subtype string
+		var attributeType, unit sql.Null[string]
+		var enable, viewer, ar, virtual sql.Null[bool]
+		var precision, seq sql.Null[int64]
+		var minimum, maximum sql.Null[float64]
+		var translation []byte
+		attribute := api.NewAssetTypeAttribute("", "")
+		if err := rows.Scan(&attribute.Name, &subtype, &attributeType, &enable, &translation, &unit, &precision,
+			&minimum, &maximum, &viewer, &ar, &seq, &virtual); err != nil {
+			return nil, err
+		}
+		attribute.SetAssetTypeName(assetTypeName)
+		attribute.Subtype = api.DataSubtype(subtype)
+		attribute.Type = *api.NewNullableString(ptr(attributeType))
+		attribute.Enable = ptr(enable)
+		attribute.Unit = *api.NewNullableString(ptr(unit))
+		attribute.Precision = *api.NewNullableInt64(ptr(precision))
+		attribute.Min = *api.NewNullableFloat64(ptr(minimum))
+		attribute.Max = *api.NewNullableFloat64(ptr(maximum))
+		attribute.Viewer = *api.NewNullableBool(ptr(viewer))
+		attribute.Ar = *api.NewNullableBool(ptr(ar))
+		attribute.Sequence = *api.NewNullableInt64(ptr(seq))
+		attribute.Virtual = *api.NewNullableBool(ptr(virtual))
+		if attribute.Translation, err = parseTranslation(translation); err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, *attribute)
+	}
+	return attributes, rows.Err()
+}
+
+func (s *Server) getAssets(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	assets, err := queryAssets(r.Context(), s.db, 0, query.Get("assetTypeName"), query.Get("projectId"))
+	writeResult(w, assets, err)
+}
+
+func (s *Server) getAsset(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid asset id %q", r.PathValue("id")))
+		return
+	}
+	assets, err := queryAssets(r.Context(), s.db, int32(id), "", "")
+	if err == nil && len(assets) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		writeResult(w, nil, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, assets[0])
+}
+
+// postAsset creates a new asset. It fails if an asset with the same global
+// asset identifier exists in the project.
+func (s *Server) postAsset(w http.ResponseWriter, r *http.Request) {
+	var asset api.Asset
+	if !readJSON(w, r, &asset) {
+		return
+	}
+	var exists bool
+	if err := s.db.QueryRowContext(r.Context(), `
+		SELECT EXISTS (SELECT 1 FROM public.asset WHERE gai = $1 AND proj_id = $2)`,
+		asset.GlobalAssetIdentifier, asset.ProjectId).Scan(&exists); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if exists {
+		writeError(w, http.StatusConflict, fmt.Errorf("asset %s already exists in project %s", asset.GlobalAssetIdentifier, asset.ProjectId))
+		return
+	}
+	s.writeUpsertedAssets(w, r, []api.Asset{asset})
+}
+
+func (s *Server) putAsset(w http.ResponseWriter, r *http.Request) {
+	var asset api.Asset
+	if !readJSON(w, r, &asset) {
+		return
+	}
+	s.writeUpsertedAssets(w, r, []api.Asset{asset})
+}
+
+// putAssetsBulk upserts the assets. They are identified by the global asset
+// identifier and project ID, regardless of the identifyBy parameter. Relations
+// between the assets are not stored.
+func (s *Server) putAssetsBulk(w http.ResponseWriter, r *http.Request) {
+	var assets []api.Asset
+	if !readJSON(w, r, &assets) {
+		return
+	}
+	s.writeUpsertedAssets(w, r, assets)
+}
+
+func (s *Server) writeUpsertedAssets(w http.ResponseWriter, r *http.Request, assets []api.Asset) {
+	var upserted []api.Asset
+	err := s.withTx(r.Context(), func(tx *sql.Tx) error {
+		for _, asset := range assets {
+			id, err := upsertAsset(r.Context(), tx, asset)
+			if err != nil {
+				return err
+			}
+			result, err := queryAssets(r.Context(), tx, id, "", "")
+			if err != nil {
+				return err
+			}
+			upserted = append(upserted, result...)
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if len(assets) == 1 && r.URL.Path != BasePath+"/assets-bulk" {
+		writeJSON(w, http.StatusOK, upserted[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, upserted)
+}
+
+// upsertAsset updates the asset with the given ID, or the one with the same
+// global asset identifier in the project, and returns its ID.
+func upsertAsset(ctx context.Context, q querier, asset api.Asset) (int32, error) {
+	args := []any{deref(asset.Id.Get()), asset.ProjectId, asset.GlobalAssetIdentifier, deref(asset.Name.Get()),
+		asset.AssetType, deref(asset.Description.Get()), deref(asset.Latitude.Get()), deref(asset.Longitude.Get()),
+		pq.Array(asset.Tags)}
+	var id int32
+	err := q.QueryRowContext(ctx, `
+		UPDATE public.asset
+		SET proj_id = $2, gai = $3, name = $4, asset_type = $5, description = $6, lat = $7, lon = $8, tags = $9
+		WHERE asset_id = $1 OR ($1 IS NULL AND gai = $3 AND proj_id = $2)
+		RETURNING asset_id`, args...).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, fmt.Errorf("updating asset %s: %w", asset.GlobalAssetIdentifier, err)
+	}
+	if err := q.QueryRowContext(ctx, `
+		INSERT INTO public.asset (proj_id, gai, name, asset_type, description, lat, lon, tags)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING asset_id`, args[1:]...).Scan(&id); err != nil {
+		return 0, fmt.Errorf("inserting asset %s: %w", asset.GlobalAssetIdentifier, err)
+	}
+	return id, nil
+}
+
+// queryAssets returns the assets matching all of the given filters. Empty
+// filters match all assets.
+func queryAssets(ctx context.Context, q querier, id int32, assetTypeName, projectID string) ([]api.Asset, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT asset_id, proj_id, gai, name, asset_type, description, lat, lon, tags
+		FROM public.asset
+		WHERE ($1 = 0 OR asset_id = $1) AND ($2 = '' OR asset_type = $2) AND ($3 = '' OR proj_id = $3)
+		ORDER BY asset_id`, id, assetTypeName, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	assets := []api.Asset{}
+	for rows.Next() {
+		var assetID int32
+		var name, description sql.Null[string]
+		var latitude, longitude sql.Null[float64]
+		asset := api.NewAsset("", "", "")
+		if err := rows.Scan(&assetID, &asset.ProjectId, &asset.GlobalAssetIdentifier, &name, &asset.AssetType,
+			&description, &latitude, &longitude, pq.Array(&asset.Tags)); err != nil {
+			return nil, err
+		}
+		asset.SetId(assetID)
+		asset.Name = *api.NewNullableString(ptr(name))
+		asset.Description = *api.NewNullableString(ptr(description))
+		asset.Latitude = *api.NewNullableFloat64(ptr(latitude))
+		asset.Longitude = *api.NewNullableFloat64(ptr(longitude))
+		assets = append(assets, *asset)
+	}
+	return assets, rows.Err()
+}
+
+func parseTranslation(data []byte) (api.NullableTranslation, error) {
+	if data == nil {
+		return api.NullableTranslation{}, nil
+	}
+	var translation api.Translation
+	if err := json.Unmarshal(data, &translation); err != nil {
+		return api.NullableTranslation{}, fmt.Errorf("parsing translation: %w", err)
+	}
+	return *api.NewNullableTranslation(&translation), nil
+}
+
+// deref returns the value p points to, or nil for SQL NULL.
+func deref[T any](p *T) any {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// ptr returns a pointer to the value of n, or nil if n is NULL.
+func ptr[T any](n sql.Null[T]) *T {
+	if !n.Valid {
+		return nil
+	}
+	return &n.V
+}
@@ -0,0 +1,169 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"testing"
+
+	api "github.com/eliona-smart-building-assistant/go-eliona-api-client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var assetColumns = []string{"asset_id", "proj_id", "gai", "name", "asset_type", "description", "lat", "lon", "tags"}
+
+func assetRow(id int64, gai string) []driver.Value {
+	return []driver.Value{id, "1", gai, "Room", "my_room", nil, 47.5, 8.7, []byte("{floor,room}")}
+}
+
+func TestGetAssetType(t *testing.T) {
+	assetTypeRow := []driver.Value{"my_room", true, "Vendor", nil, []byte(`{"en": "Room"}`), nil, "room"}
+	assetTypeColumns := []string{"asset_type", "custom", "vendor", "model", "translation", "urldoc", "icon"}
+	attributeColumns := []string{"attribute", "subtype", "attribute_type", "enable", "translation", "unit",
+		"precision", "min", "max", "viewer", "ar", "seq", "virtual"}
+	attributeRow := []driver.Value{"temperature", "input", nil, true, nil, "°C", int64(1), -20.0, 50.0, true, false, int64(0), nil}
+
+	t.Run("with attributes", func(t *testing.T) {
+		db, f := newFakeDB(t,
+			fakeResult{match: "FROM public.asset_type", columns: assetTypeColumns, rows: [][]driver.Value{assetTypeRow}},
+			fakeResult{match: "FROM public.attribute_schema", columns: attributeColumns, rows: [][]driver.Value{attributeRow}},
+		)
+		response := serve(New(db, ""), http.MethodGet, BasePath+"/asset-types/my_room?expansions=AssetType.attributes", "")
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		assert.Equal(t, []any{"my_room"}, f.args(0))
+		var assetType api.AssetType
+		decode(t, response, &assetType)
+		assert.Equal(t, "my_room", assetType.Name)
+		assert.Equal(t, "Vendor", assetType.GetVendor())
+		assert.Nil(t, assetType.Model.Get())
+		assert.Equal(t, "Room", assetType.Translation.Get().GetEn())
+		require.Len(t, assetType.Attributes, 1)
+		attribute := assetType.Attributes[0]
+		assert.Equal(t, "temperature", attribute.Name)
+		assert.Equal(t, api.SUBTYPE_INPUT, attribute.Subtype)
+		assert.Equal(t, "my_room", attribute.GetAssetTypeName())
+		assert.Equal(t, "°C", attribute.GetUnit())
+		assert.Equal(t, 50.0, attribute.GetMax())
+	})
+
+	t.Run("without attributes", func(t *testing.T) {
+		db, _ := newFakeDB(t, fakeResult{match: "FROM public.asset_type", columns: assetTypeColumns, rows: [][]driver.Value{assetTypeRow}})
+		response := serve(New(db, ""), http.MethodGet, BasePath+"/asset-types/my_room", "")
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		var assetType api.AssetType
+		decode(t, response, &assetType)
+		assert.Empty(t, assetType.Attributes)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		db, _ := newFakeDB(t, fakeResult{match: "FROM public.asset_type", columns: assetTypeColumns})
+		response := serve(New(db, ""), http.MethodGet, BasePath+"/asset-types/my_room", "")
+
+		assert.Equal(t, http.StatusNotFound, response.Code)
+	})
+}
+
+func TestPutAssetTypeRollsBack(t *testing.T) {
+	db, f := newFakeDB(t, fakeResult{match: "UPDATE public.asset_type", err: errors.New("connection lost")})
+	response := serve(New(db, ""), http.MethodPut, BasePath+"/asset-types", `{"name": "my_room", "attributes": []}`)
+
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Contains(t, response.Body.String(), "connection lost")
+	assert.Equal(t, []string{"UPDATE", "ROLLBACK"}, statementKinds(f.queries()))
+}
+
+func TestGetAsset(t *testing.T) {
+	db, f := newFakeDB(t, fakeResult{match: "FROM public.asset", columns: assetColumns, rows: [][]driver.Value{assetRow(7, "room-1")}})
+	response := serve(New(db, ""), http.MethodGet, BasePath+"/assets/7", "")
+
+	require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+	assert.Equal(t, []any{int64(7), "", ""}, f.args(0))
+	var asset api.Asset
+	decode(t, response, &asset)
+	assert.Equal(t, int32(7), asset.GetId())
+	assert.Equal(t, "room-1", asset.GlobalAssetIdentifier)
+	assert.Equal(t, "Room", asset.GetName())
+	assert.Nil(t, asset.Description.Get())
+	assert.Equal(t, []string{"floor", "room"}, asset.Tags)
+}
+
+func TestPostAsset(t *testing.T) {
+	body := `{"projectId": "1", "globalAssetIdentifier": "room-1", "assetType": "my_room", "tags": ["floor", "room"]}`
+
+	t.Run("new", func(t *testing.T) {
+		db, f := newFakeDB(t,
+			fakeResult{match: "SELECT EXISTS", columns: []string{"exists"}, rows: [][]driver.Value{{false}}},
+			fakeResult{match: "UPDATE public.asset", columns: []string{"asset_id"}},
+			fakeResult{match: "INSERT INTO public.asset", columns: []string{"asset_id"}, rows: [][]driver.Value{{int64(7)}}},
+			fakeResult{match: "FROM public.asset", columns: assetColumns, rows: [][]driver.Value{assetRow(7, "room-1")}},
+		)
+		response := serve(New(db, ""), http.MethodPost, BasePath+"/assets", body)
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		assert.Equal(t, []string{"SELECT", "UPDATE", "INSERT", "SELECT", "COMMIT"}, statementKinds(f.queries()))
+		assert.Equal(t, []any{"room-1", "1"}, f.args(0))
+		// The insert gets the arguments of the update without the ID.
+		assert.Equal(t, f.args(1)[1:], f.args(2))
+		assert.Equal(t, "{\"floor\",\"room\"}", f.args(2)[7])
+		var asset api.Asset
+		decode(t, response, &asset)
+		assert.Equal(t, int32(7), asset.GetId())
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		db, _ := newFakeDB(t, fakeResult{match: "SELECT EXISTS", columns: []string{"exists"}, rows: [][]driver.Value{{true}}})
+		response := serve(New(db, ""), http.MethodPost, BasePath+"/assets", body)
+
+		assert.Equal(t, http.StatusConflict, response.Code)
+	})
+}
+
+func TestPutAssets(t *testing.T) {
+	body := `{"projectId": "1", "globalAssetIdentifier": "room-1", "assetType": "my_room"}`
+	results := func() []fakeResult {
+		return []fakeResult{
+			{match: "UPDATE public.asset", columns: []string{"asset_id"}, rows: [][]driver.Value{{int64(7)}}},
+			{match: "FROM public.asset", columns: assetColumns, rows: [][]driver.Value{assetRow(7, "room-1")}},
+		}
+	}
+
+	t.Run("single", func(t *testing.T) {
+		db, f := newFakeDB(t, results()...)
+		response := serve(New(db, ""), http.MethodPut, BasePath+"/assets", body)
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		assert.Equal(t, []string{"UPDATE", "SELECT", "COMMIT"}, statementKinds(f.queries()))
+		var asset api.Asset
+		decode(t, response, &asset)
+		assert.Equal(t, int32(7), asset.GetId())
+	})
+
+	t.Run("bulk", func(t *testing.T) {
+		db, _ := newFakeDB(t, results()...)
+		response := serve(New(db, ""), http.MethodPut, BasePath+"/assets-bulk", "["+body+"]")
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		var assets []api.Asset
+		decode(t, response, &assets)
+		require.Len(t, assets, 1)
+		assert.Equal(t, int32(7), assets[0].GetId())
+	})
+}
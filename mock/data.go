@@ -0,0 +1,137 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	api "github.com/eliona-smart-building-assistant/go-eliona-api-client/v2"
+)
+
+// getData returns the current data of the assets, filtered by the assetId,
+// dataSubtype and assetTypeName parameters.
+func (s *Server) getData(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var assetID int64
+	if value := query.Get("assetId"); value != "" {
+		var err error
+		if assetID, err = strconv.ParseInt(value, 10, 32); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("parsing assetId: %w", err))
+			return
+		}
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT heap.asset_id, heap.subtype, heap.ts, heap.data, asset.asset_type
+		FROM public.heap
+		JOIN public.asset USING (asset_id)
+		WHERE ($1 = 0 OR heap.asset_id = $1) AND ($2 = '' OR heap.subtype = $2) AND ($3 = '' OR asset.asset_type = $3)
+		ORDER BY heap.asset_id, heap.subtype`, assetID, query.Get("dataSubtype"), query.Get("assetTypeName"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	datas := []api.Data{}
+	for rows.Next() {
+		var data api.Data
+		var subtype, assetTypeName string
+		var timestamp time.Time
+		var values []byte
+		if err := rows.Scan(&data.AssetId, &subtype, &timestamp, &values, &assetTypeName); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		data.Subtype = api.DataSubtype(subtype)
+		data.SetTimestamp(timestamp)
+		data.SetAssetTypeName(assetTypeName)
+		if err := json.Unmarshal(values, &data.Data); err != nil {
+			writeError(w, http.StatusInternalServerError, fmt.Errorf("parsing data of asset %d: %w", data.AssetId, err))
+			return
+		}
+		datas = append(datas, data)
+	}
+	writeResult(w, datas, rows.Err())
+}
+
+func (s *Server) putData(w http.ResponseWriter, r *http.Request) {
+	var data api.Data
+	if !readJSON(w, r, &data) {
+		return
+	}
+	if err := upsertData(r.Context(), s.db, data); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) putDataBulk(w http.ResponseWriter, r *http.Request) {
+	var datas []api.Data
+	if !readJSON(w, r, &datas) {
+		return
+	}
+	err := s.withTx(r.Context(), func(tx *sql.Tx) error {
+		for _, data := range datas {
+			if err := upsertData(r.Context(), tx, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// upsertData merges the data into the current data of the asset subtype, as
+// Eliona does for partial updates.
+func upsertData(ctx context.Context, q querier, data api.Data) error {
+	values, err := json.Marshal(data.Data)
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now()
+	if data.Timestamp.Get() != nil {
+		timestamp = *data.Timestamp.Get()
+	}
+	args := []any{data.AssetId, string(data.Subtype), timestamp, string(values)}
+	result, err := q.ExecContext(ctx, `
+		UPDATE public.heap
+		SET ts = $3, data = heap.data || $4::jsonb
+		WHERE asset_id = $1 AND subtype = $2`, args...)
+	if err != nil {
+		return fmt.Errorf("updating data of asset %d: %w", data.AssetId, err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		return nil
+	}
+	if _, err := q.ExecContext(ctx, `
+		INSERT INTO public.heap (asset_id, subtype, ts, data)
+		VALUES ($1, $2, $3, $4::jsonb)`, args...); err != nil {
+		return fmt.Errorf("inserting data of asset %d: %w", data.AssetId, err)
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"testing"
+	"time"
+
+	api "github.com/eliona-smart-building-assistant/go-eliona-api-client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetData(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	columns := []string{"asset_id", "subtype", "ts", "data", "asset_type"}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantArgs []any
+	}{
+		{"all", "", []any{int64(0), "", ""}},
+		{"filtered", "?assetId=7&dataSubtype=input&assetTypeName=my_room", []any{int64(7), "input", "my_room"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := newFakeDB(t, fakeResult{match: "FROM public.heap", columns: columns,
+				rows: [][]driver.Value{{int64(7), "input", timestamp, []byte(`{"temperature": 21.5}`), "my_room"}}})
+			response := serve(New(db, ""), http.MethodGet, BasePath+"/data"+tt.query, "")
+
+			require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+			assert.Equal(t, tt.wantArgs, f.args(0))
+			var datas []api.Data
+			decode(t, response, &datas)
+			require.Len(t, datas, 1)
+			assert.Equal(t, int32(7), datas[0].AssetId)
+			assert.Equal(t, api.SUBTYPE_INPUT, datas[0].Subtype)
+			assert.True(t, timestamp.Equal(datas[0].GetTimestamp()))
+			assert.Equal(t, "my_room", datas[0].GetAssetTypeName())
+			assert.Equal(t, map[string]any{"temperature": 21.5}, datas[0].Data)
+		})
+	}
+
+	t.Run("none", func(t *testing.T) {
+		db, _ := newFakeDB(t, fakeResult{match: "FROM public.heap", columns: columns})
+		response := serve(New(db, ""), http.MethodGet, BasePath+"/data", "")
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		assert.JSONEq(t, "[]", response.Body.String())
+	})
+}
+
+func TestPutData(t *testing.T) {
+	body := `{"assetId": 7, "subtype": "input", "timestamp": "2024-01-02T15:04:05Z", "data": {"temperature": 21.5}}`
+	tests := []struct {
+		name     string
+		affected int64
+		want     []string
+	}{
+		{"existing", 1, []string{"UPDATE"}},
+		{"new", 0, []string{"UPDATE", "INSERT"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := []fakeResult{{match: "UPDATE public.heap SET ts = $3, data = heap.data || $4::jsonb", affected: tt.affected}}
+			if tt.affected == 0 {
+				results = append(results, fakeResult{match: "INSERT INTO public.heap", affected: 1})
+			}
+			db, f := newFakeDB(t, results...)
+			response := serve(New(db, ""), http.MethodPut, BasePath+"/data", body)
+
+			require.Equal(t, http.StatusNoContent, response.Code, "body %s", response.Body)
+			assert.Equal(t, tt.want, statementKinds(f.queries()))
+			want := []any{int64(7), "input", time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC), `{"temperature":21.5}`}
+			for i := range tt.want {
+				assert.Equal(t, want, f.args(i))
+			}
+		})
+	}
+}
+
+func TestPutDataBulk(t *testing.T) {
+	db, f := newFakeDB(t,
+		fakeResult{match: "UPDATE public.heap", affected: 1},
+		fakeResult{match: "UPDATE public.heap", affected: 1},
+	)
+	response := serve(New(db, ""), http.MethodPut, BasePath+"/data-bulk",
+		`[{"assetId": 7, "subtype": "input", "data": {"a": 1}}, {"assetId": 8, "subtype": "info", "data": {"b": 2}}]`)
+
+	require.Equal(t, http.StatusNoContent, response.Code, "body %s", response.Body)
+	assert.Equal(t, []string{"UPDATE", "UPDATE", "COMMIT"}, statementKinds(f.queries()))
+	assert.Equal(t, int64(8), f.args(1)[0])
+	assert.Equal(t, "info", f.args(1)[1])
+}
@@ -0,0 +1,171 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package mock implements the part of the Eliona v2 API used by apps: apps and
+// patches, asset types, assets, data, widget types and dashboards. Everything
+// is read from and written to the Eliona tables in the database, so that the
+// checks in the assert package see what an app created through the mock.
+package mock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// BasePath is the path the API is served under, as in Eliona.
+const BasePath = "/v2"
+
+// Server is an in-process mock of the Eliona API.
+type Server struct {
+	db      *sql.DB
+	token   string
+	handler http.Handler
+}
+
+// New returns a mock of the Eliona API backed by the given database. If token
+// is not empty, requests have to authenticate with it in the X-API-Key header.
+func New(db *sql.DB, token string) *Server {
+	s := &Server{db: db, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+BasePath+"/version", s.getVersion)
+
+	mux.HandleFunc("GET "+BasePath+"/apps/{app}", s.getApp)
+	mux.HandleFunc("PATCH "+BasePath+"/apps/{app}", s.patchApp)
+	mux.HandleFunc("GET "+BasePath+"/apps/{app}/patches/{patch}", s.getPatch)
+	mux.HandleFunc("PATCH "+BasePath+"/apps/{app}/patches/{patch}", s.patchPatch)
+
+	mux.HandleFunc("GET "+BasePath+"/asset-types", s.getAssetTypes)
+	mux.HandleFunc("PUT "+BasePath+"/asset-types", s.putAssetType)
+	mux.HandleFunc("GET "+BasePath+"/asset-types/{type}", s.getAssetType)
+	mux.HandleFunc("PUT "+BasePath+"/asset-types/{type}/attributes", s.putAssetTypeAttribute)
+
+	mux.HandleFunc("GET "+BasePath+"/assets", s.getAssets)
+	mux.HandleFunc("POST "+BasePath+"/assets", s.postAsset)
+	mux.HandleFunc("PUT "+BasePath+"/assets", s.putAsset)
+	mux.HandleFunc("GET "+BasePath+"/assets/{id}", s.getAsset)
+	mux.HandleFunc("PUT "+BasePath+"/assets-bulk", s.putAssetsBulk)
+
+	mux.HandleFunc("GET "+BasePath+"/data", s.getData)
+	mux.HandleFunc("PUT "+BasePath+"/data", s.putData)
+	mux.HandleFunc("PUT "+BasePath+"/data-bulk", s.putDataBulk)
+
+	mux.HandleFunc("GET "+BasePath+"/widget-types", s.getWidgetTypes)
+	mux.HandleFunc("PUT "+BasePath+"/widget-types", s.putWidgetType)
+	mux.HandleFunc("GET "+BasePath+"/widget-types/{type}", s.getWidgetType)
+
+	mux.HandleFunc("GET "+BasePath+"/dashboards", s.getDashboards)
+	mux.HandleFunc("POST "+BasePath+"/dashboards", s.postDashboard)
+	mux.HandleFunc("GET "+BasePath+"/dashboards/{id}", s.getDashboard)
+
+	s.handler = s.authenticate(mux)
+	return s
+}
+
+// Handler returns the handler serving the API, e.g. to wrap it or to serve it
+// with httptest.
+func (s *Server) Handler() http.Handler {
+	return s.handler
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && r.Header.Get("X-API-Key") != s.token {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing API key"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) getVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"version": "mock"})
+}
+
+// readJSON decodes the request body into v and reports a bad request if it fails.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decoding request body: %w", err))
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"message": err.Error()})
+}
+
+// writeResult writes v, or the error with a status matching it.
+func writeResult(w http.ResponseWriter, v any, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err)
+	default:
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+// expansions returns whether the given expansion, e.g. "AssetType.attributes",
+// is requested by the expansions query parameter.
+func expansions(r *http.Request, expansion string) bool {
+	for _, value := range r.URL.Query()["expansions"] {
+		for _, e := range strings.Split(value, ",") {
+			if e == expansion {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nullJSON returns the JSON encoding of v, or nil for SQL NULL if v is nil.
+func nullJSON(v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	return string(data), nil
+}
+
+// withTx runs f in a transaction, which is committed if f succeeds.
+func (s *Server) withTx(ctx context.Context, f func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(tx); err != nil {
+		return errors.Join(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,282 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDB is a database driver answering the statements of the handlers with
+// scripted results in order, so that the handlers can be tested without
+// Postgres. Every statement has to match the next result.
+type fakeDB struct {
+	mu         sync.Mutex
+	results    []fakeResult
+	statements []fakeStatement
+}
+
+// fakeResult is the result of a statement containing match. Queries return the
+// columns and rows, other statements the number of affected rows.
+type fakeResult struct {
+	match    string
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+type fakeStatement struct {
+	query string
+	args  []any
+}
+
+// newFakeDB returns a database answering with the given results. The test
+// fails if not all of them were used.
+func newFakeDB(t *testing.T, results ...fakeResult) (*sql.DB, *fakeDB) {
+	t.Helper()
+	f := &fakeDB{results: results}
+	db := sql.OpenDB(f)
+	t.Cleanup(func() {
+		_ = db.Close()
+		assert.Empty(t, f.results, "unused database results")
+	})
+	return db, f
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return f }
+func (f *fakeDB) Open(string) (driver.Conn, error)             { return &fakeConn{f}, nil }
+
+// queries returns the statements run so far, including COMMIT and ROLLBACK.
+func (f *fakeDB) queries() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var queries []string
+	for _, statement := range f.statements {
+		queries = append(queries, statement.query)
+	}
+	return queries
+}
+
+// args returns the arguments of the i-th statement.
+func (f *fakeDB) args(i int) []any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.statements[i].args
+}
+
+func (f *fakeDB) record(query string, args []driver.NamedValue) {
+	statement := fakeStatement{query: strings.Join(strings.Fields(query), " ")}
+	for _, arg := range args {
+		statement.args = append(statement.args, arg.Value)
+	}
+	f.statements = append(f.statements, statement)
+}
+
+func (f *fakeDB) next(query string, args []driver.NamedValue) (fakeResult, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record(query, args)
+	if len(f.results) == 0 {
+		return fakeResult{}, fmt.Errorf("unexpected statement %q", query)
+	}
+	result := f.results[0]
+	if !strings.Contains(strings.Join(strings.Fields(query), " "), result.match) {
+		return fakeResult{}, fmt.Errorf("statement %q does not contain %q", query, result.match)
+	}
+	f.results = f.results[1:]
+	return result, result.err
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("prepared statements are not supported")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.record("COMMIT", nil)
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.record("ROLLBACK", nil)
+	return nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	result, err := c.db.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(result.affected), nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	result, err := c.db.next(query, args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeRows{columns: result.columns, rows: result.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// serve sends the request to the handler of the mock and returns the response.
+func serve(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	response := httptest.NewRecorder()
+	s.Handler().ServeHTTP(response, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return response
+}
+
+// decode decodes the JSON body of the response into v.
+func decode(t *testing.T, response *httptest.ResponseRecorder, v any) {
+	t.Helper()
+	assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), v), "body %s", response.Body)
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"no token", "", "", http.StatusOK},
+		{"token ignored", "", "other", http.StatusOK},
+		{"missing key", "secret", "", http.StatusUnauthorized},
+		{"wrong key", "secret", "other", http.StatusUnauthorized},
+		{"valid key", "secret", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := newFakeDB(t)
+			request := httptest.NewRequest(http.MethodGet, BasePath+"/version", nil)
+			if tt.header != "" {
+				request.Header.Set("X-API-Key", tt.header)
+			}
+			response := httptest.NewRecorder()
+			New(db, tt.token).Handler().ServeHTTP(response, request)
+
+			assert.Equal(t, tt.want, response.Code)
+			var body map[string]string
+			decode(t, response, &body)
+			if tt.want == http.StatusOK {
+				assert.Equal(t, "mock", body["version"])
+			} else {
+				assert.NotEmpty(t, body["message"])
+			}
+		})
+	}
+}
+
+// TestBadRequests checks that invalid parameters and bodies are rejected
+// before the database is used.
+func TestBadRequests(t *testing.T) {
+	tests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{http.MethodPatch, "/apps/app?registered=maybe", ""},
+		{http.MethodPatch, "/apps/app/patches/v1?apply=maybe", ""},
+		{http.MethodGet, "/assets/abc", ""},
+		{http.MethodGet, "/assets/0", ""},
+		{http.MethodPost, "/assets", "{"},
+		{http.MethodPut, "/assets-bulk", `{"projectId": "1"}`},
+		{http.MethodPut, "/asset-types", "[]"},
+		{http.MethodGet, "/data?assetId=abc", ""},
+		{http.MethodPut, "/data", ""},
+		{http.MethodPut, "/widget-types", "widget"},
+		{http.MethodGet, "/dashboards/-1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.target, func(t *testing.T) {
+			db, _ := newFakeDB(t)
+			response := serve(New(db, ""), tt.method, BasePath+tt.target, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, response.Code)
+			var body map[string]string
+			decode(t, response, &body)
+			assert.NotEmpty(t, body["message"])
+		})
+	}
+}
+
+func TestExpansions(t *testing.T) {
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{"", false},
+		{"expansions=AssetType.attributes", true},
+		{"expansions=Asset.tags,AssetType.attributes", true},
+		{"expansions=Asset.tags&expansions=AssetType.attributes", true},
+		{"expansions=AssetType", false},
+	}
+	for _, tt := range tests {
+		request := httptest.NewRequest(http.MethodGet, BasePath+"/asset-types?"+tt.query, nil)
+		assert.Equal(t, tt.want, expansions(request, "AssetType.attributes"), "query %q", tt.query)
+	}
+}
+
+// statementKinds returns the first word of each query, e.g. "INSERT".
+func statementKinds(queries []string) []string {
+	var kinds []string
+	for _, query := range queries {
+		kinds = append(kinds, strings.Fields(query)[0])
+	}
+	return kinds
+}
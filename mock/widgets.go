@@ -0,0 +1,269 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	api "github.com/eliona-smart-building-assistant/go-eliona-api-client/v2"
+)
+
+func (s *Server) getWidgetTypes(w http.ResponseWriter, r *http.Request) {
+	widgetTypes, err := queryWidgetTypes(r.Context(), s.db, "")
+	writeResult(w, widgetTypes, err)
+}
+
+func (s *Server) getWidgetType(w http.ResponseWriter, r *http.Request) {
+	widgetTypes, err := queryWidgetTypes(r.Context(), s.db, r.PathValue("type"))
+	if err == nil && len(widgetTypes) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		writeResult(w, nil, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, widgetTypes[0])
+}
+
+// putWidgetType upserts the widget type identified by its name and replaces
+// its elements.
+func (s *Server) putWidgetType(w http.ResponseWriter, r *http.Request) {
+	var widgetType api.WidgetType
+	if !readJSON(w, r, &widgetType) {
+		return
+	}
+
+	var widgetTypes []api.WidgetType
+	err := s.withTx(r.Context(), func(tx *sql.Tx) error {
+		if err := upsertWidgetType(r.Context(), tx, widgetType); err != nil {
+			return err
+		}
+		var err error
+		widgetTypes, err = queryWidgetTypes(r.Context(), tx, widgetType.Name)
+		return err
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, widgetTypes[0])
+}
+
+func upsertWidgetType(ctx context.Context, q querier, widgetType api.WidgetType) error {
+	translation, err := nullJSON(widgetType.Translation.Get())
+	if err != nil {
+		return err
+	}
+	custom := widgetType.Custom == nil || *widgetType.Custom
+	args := []any{widgetType.Name, custom, translation, deref(widgetType.Icon.Get()),
+		deref(widgetType.WithAlarm.Get()), deref(widgetType.WithTimespan.Get())}
+	var id int32
+	err = q.QueryRowContext(ctx, `
+		UPDATE public.widget_type
+		SET custom = $2, translation = $3, icon = $4, with_alarm = $5, with_timespan = $6
+		WHERE name = $1
+		RETURNING id`, args...).Scan(&id)
+	if err == sql.ErrNoRows {
+		err = q.QueryRowContext(ctx, `
+			INSERT INTO public.widget_type (name, custom, translation, icon, with_alarm, with_timespan)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id`, args...).Scan(&id)
+	}
+	if err != nil {
+		return fmt.Errorf("upserting widget type %s: %w", widgetType.Name, err)
+	}
+
+	if _, err := q.ExecContext(ctx, `DELETE FROM public.widget_type_element WHERE type_id = $1`, id); err != nil {
+		return fmt.Errorf("deleting elements of widget type %s: %w", widgetType.Name, err)
+	}
+	for i, element := range widgetType.Elements {
+		config, err := nullJSON(element.Config)
+		if err != nil {
+			return err
+		}
+		sequence := int32(i)
+		if element.Sequence.Get() != nil {
+			sequence = *element.Sequence.Get()
+		}
+		if _, err := q.ExecContext(ctx, `
+			INSERT INTO public.widget_type_element (type_id, category, seq, config)
+			VALUES ($1, $2, $3, $4)`, id, element.Category, sequence, config); err != nil {
+			return fmt.Errorf("inserting element of widget type %s: %w", widgetType.Name, err)
+		}
+	}
+	return nil
+}
+
+// queryWidgetTypes returns the widget type with the given name, or all widget
+// types if the name is empty.
+func queryWidgetTypes(ctx context.Context, q querier, name string) ([]api.WidgetType, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, name, custom, translation, icon, with_alarm, with_timespan
+		FROM public.widget_type
+		WHERE $1 = '' OR name = $1
+		ORDER BY name`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	widgetTypes := []api.WidgetType{}
+	for rows.Next() {
+		var id int32
+		var custom, withAlarm, withTimespan sql.Null[bool]
+		var icon sql.Null[string]
+		var translation []byte
+		widgetType := api.NewWidgetType("", api.NullableTranslation{}, nil)
+		if err := rows.Scan(&id, &widgetType.Name, &custom, &translation, &icon, &withAlarm, &withTimespan); err != nil {
+			return nil, err
+		}
+		widgetType.SetId(id)
+		widgetType.Custom = ptr(custom)
+		widgetType.Icon = *api.NewNullableString(ptr(icon))
+		widgetType.WithAlarm = *api.NewNullableBool(ptr(withAlarm))
+		widgetType.WithTimespan = *api.NewNullableBool(ptr(withTimespan))
+		if widgetType.Translation, err = parseTranslation(translation); err != nil {
+			return nil, err
+		}
+		widgetTypes = append(widgetTypes, *widgetType)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range widgetTypes {
+		if widgetTypes[i].Elements, err = queryWidgetTypeElements(ctx, q, *widgetTypes[i].Id.Get()); err != nil {
+			return nil, err
+		}
+	}
+	return widgetTypes, nil
+}
+
+func queryWidgetTypeElements(ctx context.Context, q querier, typeID int32) ([]api.WidgetTypeElement, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, category, seq, config
+		FROM public.widget_type_element
+		WHERE type_id = $1
+		ORDER BY seq`, typeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	elements := []api.WidgetTypeElement{}
+	for rows.Next() {
+		var id int32
+		var sequence sql.Null[int32]
+		var config []byte
+		element := api.NewWidgetTypeElement("")
+		if err := rows.Scan(&id, &element.Category, &sequence, &config); err != nil {
+			return nil, err
+		}
+		element.SetId(id)
+		element.Sequence = *api.NewNullableInt32(ptr(sequence))
+		if config != nil {
+			if err := json.Unmarshal(config, &element.Config); err != nil {
+				return nil, fmt.Errorf("parsing config of widget type element %d: %w", id, err)
+			}
+		}
+		elements = append(elements, *element)
+	}
+	return elements, rows.Err()
+}
+
+func (s *Server) getDashboards(w http.ResponseWriter, r *http.Request) {
+	dashboards, err := queryDashboards(r.Context(), s.db, 0)
+	writeResult(w, dashboards, err)
+}
+
+func (s *Server) getDashboard(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 32)
+	if err != nil || id <= 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid dashboard id %q", r.PathValue("id")))
+		return
+	}
+	dashboards, err := queryDashboards(r.Context(), s.db, int32(id))
+	if err == nil && len(dashboards) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		writeResult(w, nil, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dashboards[0])
+}
+
+// postDashboard creates a dashboard. The widgets of the dashboard are not
+// stored by the mock.
+func (s *Server) postDashboard(w http.ResponseWriter, r *http.Request) {
+	var dashboard api.Dashboard
+	if !readJSON(w, r, &dashboard) {
+		return
+	}
+	var id int32
+	if err := s.db.QueryRowContext(r.Context(), `
+		INSERT INTO public.dashboard (name, proj_id, user_id, seq, public)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING dashboard_id`, dashboard.Name, dashboard.ProjectId, dashboard.UserId,
+		deref(dashboard.Sequence.Get()), deref(dashboard.Public.Get())).Scan(&id); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("inserting dashboard %s: %w", dashboard.Name, err))
+		return
+	}
+	dashboards, err := queryDashboards(r.Context(), s.db, id)
+	if err == nil && len(dashboards) == 0 {
+		err = sql.ErrNoRows
+	}
+	if err != nil {
+		writeResult(w, nil, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, dashboards[0])
+}
+
+// queryDashboards returns the dashboard with the given ID, or all dashboards
+// if the ID is zero.
+func queryDashboards(ctx context.Context, q querier, id int32) ([]api.Dashboard, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT dashboard_id, name, proj_id, user_id, seq, public
+		FROM public.dashboard
+		WHERE $1 = 0 OR dashboard_id = $1
+		ORDER BY dashboard_id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dashboards := []api.Dashboard{}
+	for rows.Next() {
+		var dashboardID int32
+		var sequence sql.Null[int32]
+		var public sql.Null[bool]
+		dashboard := api.NewDashboard("", "", "")
+		if err := rows.Scan(&dashboardID, &dashboard.Name, &dashboard.ProjectId, &dashboard.UserId, &sequence, &public); err != nil {
+			return nil, err
+		}
+		dashboard.SetId(dashboardID)
+		dashboard.Sequence = *api.NewNullableInt32(ptr(sequence))
+		dashboard.Public = *api.NewNullableBool(ptr(public))
+		dashboards = append(dashboards, *dashboard)
+	}
+	return dashboards, rows.Err()
+}
@@ -0,0 +1,95 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package mock
+
+import (
+	"database/sql/driver"
+	"net/http"
+	"testing"
+
+	api "github.com/eliona-smart-building-assistant/go-eliona-api-client/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	widgetTypeColumns  = []string{"id", "name", "custom", "translation", "icon", "with_alarm", "with_timespan"}
+	widgetTypeRow      = []driver.Value{int64(3), "my_widget", true, []byte(`{"en": "Widget"}`), nil, false, nil}
+	elementColumns     = []string{"id", "category", "seq", "config"}
+	widgetElementsRows = [][]driver.Value{
+		{int64(10), "timeseries", int64(0), []byte(`{"unit": "°C"}`)},
+		{int64(11), "value", int64(1), nil},
+	}
+)
+
+func TestGetWidgetType(t *testing.T) {
+	t.Run("known", func(t *testing.T) {
+		db, f := newFakeDB(t,
+			fakeResult{match: "FROM public.widget_type ", columns: widgetTypeColumns, rows: [][]driver.Value{widgetTypeRow}},
+			fakeResult{match: "FROM public.widget_type_element", columns: elementColumns, rows: widgetElementsRows},
+		)
+		response := serve(New(db, ""), http.MethodGet, BasePath+"/widget-types/my_widget", "")
+
+		require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+		assert.Equal(t, []any{"my_widget"}, f.args(0))
+		assert.Equal(t, []any{int64(3)}, f.args(1))
+		var widgetType api.WidgetType
+		decode(t, response, &widgetType)
+		assert.Equal(t, int32(3), widgetType.GetId())
+		assert.Equal(t, "Widget", widgetType.Translation.Get().GetEn())
+		require.Len(t, widgetType.Elements, 2)
+		assert.Equal(t, "timeseries", widgetType.Elements[0].Category)
+		assert.Equal(t, map[string]any{"unit": "°C"}, widgetType.Elements[0].Config)
+		assert.Nil(t, widgetType.Elements[1].Config)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		db, _ := newFakeDB(t, fakeResult{match: "FROM public.widget_type ", columns: widgetTypeColumns})
+		response := serve(New(db, ""), http.MethodGet, BasePath+"/widget-types/my_widget", "")
+
+		assert.Equal(t, http.StatusNotFound, response.Code)
+	})
+}
+
+// TestPutWidgetType checks that a new widget type is inserted and that its
+// elements replace the existing ones.
+func TestPutWidgetType(t *testing.T) {
+	db, f := newFakeDB(t,
+		fakeResult{match: "UPDATE public.widget_type", columns: []string{"id"}},
+		fakeResult{match: "INSERT INTO public.widget_type ", columns: []string{"id"}, rows: [][]driver.Value{{int64(3)}}},
+		fakeResult{match: "DELETE FROM public.widget_type_element WHERE type_id = $1", affected: 2},
+		fakeResult{match: "INSERT INTO public.widget_type_element", affected: 1},
+		fakeResult{match: "INSERT INTO public.widget_type_element", affected: 1},
+		fakeResult{match: "FROM public.widget_type ", columns: widgetTypeColumns, rows: [][]driver.Value{widgetTypeRow}},
+		fakeResult{match: "FROM public.widget_type_element", columns: elementColumns, rows: widgetElementsRows},
+	)
+	response := serve(New(db, ""), http.MethodPut, BasePath+"/widget-types", `{"name": "my_widget",
+		"translation": {"en": "Widget"}, "elements": [
+			{"category": "timeseries", "config": {"unit": "°C"}},
+			{"category": "value", "sequence": 5}
+		]}`)
+
+	require.Equal(t, http.StatusOK, response.Code, "body %s", response.Body)
+	assert.Equal(t, []string{"UPDATE", "INSERT", "DELETE", "INSERT", "INSERT", "SELECT", "SELECT", "COMMIT"},
+		statementKinds(f.queries()))
+	assert.Equal(t, []any{int64(3)}, f.args(2))
+	assert.Equal(t, []any{int64(3), "timeseries", int64(0), `{"unit":"°C"}`}, f.args(3))
+	assert.Equal(t, []any{int64(3), "value", int64(5), nil}, f.args(4))
+	var widgetType api.WidgetType
+	decode(t, response, &widgetType)
+	assert.Equal(t, "my_widget", widgetType.Name)
+	assert.Len(t, widgetType.Elements, 2)
+}
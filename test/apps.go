@@ -17,12 +17,25 @@ package test
 
 import (
 	"testing"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
 )
 
 func AppWorks(t *testing.T) {
+	app.Watch(t)
+
+	// The checks run one after another, since the later ones stop and restart
+	// the app. Only checks not talking to the app run in parallel. The
+	// malformed requests come last, since the app may accept some of them.
+	t.Run("TestVersionEndpoint", VersionEndpointExists)
+	t.Run("TestAPISpecEndpoint", APISpecEndpointExists)
+	t.Run("TestAPISpecFile", APISpecMatchesFile)
+	t.Run("TestGetEndpoints", GetEndpointsMatchSpec)
+	t.Run("TestDatabaseCatalog", AppStaysInItsSchema)
 	t.Run("TestAppInitialization", AppIsInitialized)
 	t.Run("TestAppStore", CanAddAppToStore)
 	t.Run("TestIconFile", IconFileIsValid)
-	t.Run("TestVersionEndpoint", VersionEndpointExists)
-	t.Run("TestAPISpecEndpoint", APISpecEndpointExists)
+	t.Run("TestGracefulShutdown", AppShutsDownGracefully)
+	t.Run("TestResetScript", ResetScriptIsComplete)
+	t.Run("TestMalformedRequests", EndpointsRejectMalformedRequests)
 }
@@ -0,0 +1,122 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// contractClient is the client calling the operations of the app. Requests
+// taking longer than the timeout count as hung.
+var contractClient = &http.Client{Timeout: 30 * time.Second}
+
+// GetEndpointsMatchSpec calls every GET operation of the API specification
+// that needs no parameters and checks that the status code, the content type
+// and the response body are declared by the specification.
+func GetEndpointsMatchSpec(t *testing.T) {
+	spec := getAPISpec(t)
+	metadata := getMetadata(t)
+
+	paths := spec.Paths.Map()
+	for _, path := range slices.Sorted(maps.Keys(paths)) {
+		operation := paths[path].Get
+		if operation == nil || !isParameterless(path, paths[path], operation) {
+			continue
+		}
+		t.Run("GET "+path, func(t *testing.T) {
+			url := fmt.Sprintf("%s/%s%s", app.BaseURL(), metadata.ApiUrl, path)
+			resp, err := contractClient.Get(url)
+			require.NoErrorf(t, err, "%s should be accessible", url)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err, "Reading response body")
+
+			assert.NoError(t, validateResponse(operation, resp, body))
+		})
+	}
+}
+
+// isParameterless reports whether an operation can be called without any
+// parameters, i.e. the path has no templates and no parameter is required.
+func isParameterless(path string, pathItem *openapi3.PathItem, operation *openapi3.Operation) bool {
+	if strings.Contains(path, "{") {
+		return false
+	}
+	for _, parameter := range slices.Concat(pathItem.Parameters, operation.Parameters) {
+		if parameter.Value != nil && parameter.Value.Required {
+			return false
+		}
+	}
+	return true
+}
+
+// validateResponse checks a response of the app against the responses
+// declared for the operation.
+func validateResponse(operation *openapi3.Operation, resp *http.Response, body []byte) error {
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("status %d: server error: %s", resp.StatusCode, body)
+	}
+	response := operation.Responses.Status(resp.StatusCode)
+	if response == nil {
+		response = operation.Responses.Default()
+	}
+	if response == nil || response.Value == nil {
+		return fmt.Errorf("status %d is not declared", resp.StatusCode)
+	}
+	if len(response.Value.Content) == 0 {
+		return nil
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("status %d: invalid content type %q: %w", resp.StatusCode, contentType, err)
+	}
+	declared := response.Value.Content.Get(mediaType)
+	if declared == nil {
+		return fmt.Errorf("status %d: content type %s is not declared", resp.StatusCode, mediaType)
+	}
+	if declared.Schema == nil || declared.Schema.Value == nil || !isJSONMediaType(mediaType) {
+		return nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Errorf("status %d: decoding response body: %w", resp.StatusCode, err)
+	}
+	if err := declared.Schema.Value.VisitJSON(value, openapi3.VisitAsResponse(), openapi3.MultiErrors()); err != nil {
+		return fmt.Errorf("status %d: response body does not match schema: %w", resp.StatusCode, err)
+	}
+	return nil
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
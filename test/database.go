@@ -0,0 +1,39 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// AppStaysInItsSchema checks that the app did not add, alter or remove
+// database objects outside its own schema while initializing. Apps have to
+// use the Eliona API for everything else.
+func AppStaysInItsSchema(t *testing.T) {
+	h := app.Default()
+	if h == nil {
+		t.Skip("App is not started by the harness")
+	}
+
+	changes, err := h.CatalogChanges(context.Background())
+	require.NoError(t, err, "Comparing database catalog")
+	assert.Empty(t, changes, "App shouldn't change the database outside its schema")
+}
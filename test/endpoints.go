@@ -19,7 +19,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
 	eapp "github.com/eliona-smart-building-assistant/go-eliona/app"
@@ -32,24 +34,70 @@ type VersionResponse struct {
 	Timestamp string `json:"timestamp"`
 }
 
+// maxBuildTimeDrift is how far the timestamp of the version may be from the
+// build time of the app.
+const maxBuildTimeDrift = 30 * time.Minute
+
+// fullCommitPattern matches a full SHA-1 or SHA-256 git commit hash.
+var fullCommitPattern = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// VersionEndpointExists checks the version of the app. The commit has to be
+// the full hash of the HEAD of the app repository, or of any commit for a
+// prebuilt image, and the timestamp an RFC 3339 time close to the build time
+// of the app. Both are set when the image is built by the Dockerfile. Apps run
+// without a container usually have no build information and may return empty
+// strings, but only for both fields together.
 func VersionEndpointExists(t *testing.T) {
 	metadata := getMetadata(t)
-	resp := getUrl(t, fmt.Sprintf("http://localhost:3039/%s/version", metadata.ApiUrl))
+	resp := getUrl(t, fmt.Sprintf("%s/%s/version", app.BaseURL(), metadata.ApiUrl))
 	defer resp.Body.Close()
 
-	versionResponse := decodeResponse[VersionResponse](t, resp)
-	if app.StartMode() == app.StartModeDocker {
+	fields := decodeResponse[map[string]json.RawMessage](t, resp)
+	var versionResponse VersionResponse
+	for _, field := range []struct {
+		name  string
+		value *string
+	}{
+		{"commit", &versionResponse.Commit},
+		{"timestamp", &versionResponse.Timestamp},
+	} {
+		raw, present := fields[field.name]
+		require.Truef(t, present, "%s field is present", field.name)
+		require.NoErrorf(t, json.Unmarshal(raw, field.value), "%s field is a string", field.name)
+	}
+
+	if app.RunsInContainer() {
 		assert.NotEmpty(t, versionResponse.Commit, "Commit field is not empty")
 		assert.NotEmpty(t, versionResponse.Timestamp, "Timestamp field is not empty")
+	} else {
+		assert.Equalf(t, versionResponse.Commit == "", versionResponse.Timestamp == "",
+			"Commit %q and timestamp %q are both set or both empty", versionResponse.Commit, versionResponse.Timestamp)
+	}
+
+	// A prebuilt image, e.g. a released one, may be built from another commit
+	// than the one checked out.
+	if versionResponse.Commit != "" {
+		assert.Regexp(t, fullCommitPattern, versionResponse.Commit, "Commit is a full git commit hash")
+		if app.StartMode() != app.StartModeImage {
+			head, err := gitHead(".")
+			if assert.NoError(t, err, "Reading HEAD of app repository") {
+				assert.Equal(t, head, versionResponse.Commit, "Commit is the HEAD of the app repository")
+			}
+		}
+	}
+
+	if versionResponse.Timestamp != "" {
+		timestamp, err := time.Parse(time.RFC3339, versionResponse.Timestamp)
+		if assert.NoError(t, err, "Timestamp is an RFC 3339 time") {
+			if h := app.Default(); h != nil {
+				assert.WithinDurationf(t, h.BuildTime(), timestamp, maxBuildTimeDrift, "Timestamp is close to the build time %s", h.BuildTime().Format(time.RFC3339))
+			}
+		}
 	}
 }
 
 func APISpecEndpointExists(t *testing.T) {
-	metadata := getMetadata(t)
-	resp := getUrl(t, fmt.Sprintf("http://localhost:3039/%s/%s", metadata.ApiUrl, metadata.ApiSpecificationPath))
-	defer resp.Body.Close()
-
-	_ = decodeResponse[any](t, resp)
+	_ = getAPISpec(t)
 }
 
 func decodeResponse[T any](t *testing.T, resp *http.Response) T {
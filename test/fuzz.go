@@ -0,0 +1,441 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// fuzzTimeout is the time after which a request counts as hung.
+	fuzzTimeout = 10 * time.Second
+	// oversizedBodySize is the size of the oversized request bodies.
+	oversizedBodySize = 8 << 20
+	// maxExampleDepth limits the nesting of generated request bodies.
+	maxExampleDepth = 5
+)
+
+var fuzzClient = &http.Client{Timeout: fuzzTimeout}
+
+// fuzzRequest is a malformed request to an operation of the app. It violates
+// the specification, so the app has to reject it.
+type fuzzRequest struct {
+	name  string
+	path  string
+	query url.Values
+	body  []byte
+	// oversized requests may be rejected by closing the connection early.
+	oversized bool
+}
+
+// EndpointsRejectMalformedRequests sends malformed requests generated from the
+// API specification to every operation: invalid path and query parameters,
+// bodies of the wrong type, bodies missing required fields and oversized
+// bodies. The app has to answer each of them in time without a server error
+// and without logging errors. Only requests violating the specification are
+// sent, but the app may still accept some of them, so it is restarted
+// afterwards, which resets the database.
+func EndpointsRejectMalformedRequests(t *testing.T) {
+	spec := getAPISpec(t)
+	metadata := getMetadata(t)
+	t.Cleanup(func() { restartApp(t) })
+
+	paths := spec.Paths.Map()
+	for _, path := range slices.Sorted(maps.Keys(paths)) {
+		pathItem := paths[path]
+		operations := pathItem.Operations()
+		for _, method := range slices.Sorted(maps.Keys(operations)) {
+			requests := malformedRequests(path, pathItem, operations[method])
+			if len(requests) == 0 {
+				continue
+			}
+			t.Run(method+" "+path, func(t *testing.T) {
+				app.Watch(t)
+				for _, request := range requests {
+					err := sendFuzzRequest(fmt.Sprintf("%s/%s", app.BaseURL(), metadata.ApiUrl), method, request)
+					assert.NoErrorf(t, err, "%s %s with %s", method, path, request.name)
+				}
+			})
+		}
+	}
+}
+
+// sendFuzzRequest sends the request and returns an error if the app answers
+// with a server error, does not answer in time or is not reachable.
+func sendFuzzRequest(baseURL, method string, request fuzzRequest) error {
+	target := baseURL + request.path
+	if len(request.query) > 0 {
+		target += "?" + request.query.Encode()
+	}
+	var body io.Reader
+	if request.body != nil {
+		body = bytes.NewReader(request.body)
+	}
+	req, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	if request.body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := fuzzClient.Do(req)
+	var netErr net.Error
+	switch {
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return fmt.Errorf("request hung for more than %s", fuzzTimeout)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Errorf("app is not reachable anymore: %w", err)
+	case err != nil && request.oversized:
+		return nil
+	case err != nil:
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("server error %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+// malformedRequests generates malformed requests for an operation. Each
+// request differs from a valid request in one aspect only. Candidates the
+// specification allows, like a number for a string parameter, are left out.
+func malformedRequests(path string, pathItem *openapi3.PathItem, operation *openapi3.Operation) []fuzzRequest {
+	var parameters []*openapi3.Parameter
+	for _, parameter := range slices.Concat(pathItem.Parameters, operation.Parameters) {
+		if parameter.Value != nil {
+			parameters = append(parameters, parameter.Value)
+		}
+	}
+
+	pathValues := make(map[string]string)
+	query := url.Values{}
+	for _, parameter := range parameters {
+		switch parameter.In {
+		case openapi3.ParameterInPath:
+			pathValues[parameter.Name] = fmt.Sprint(exampleValue(parameterSchema(parameter), 0))
+		case openapi3.ParameterInQuery:
+			if parameter.Required {
+				query.Set(parameter.Name, fmt.Sprint(exampleValue(parameterSchema(parameter), 0)))
+			}
+		}
+	}
+	valid := fuzzRequest{path: expandPath(path, pathValues), query: query}
+
+	var bodySchema *openapi3.Schema
+	bodyRequired := false
+	if operation.RequestBody != nil && operation.RequestBody.Value != nil {
+		if mediaType := operation.RequestBody.Value.Content.Get("application/json"); mediaType != nil && mediaType.Schema != nil {
+			bodySchema = mediaType.Schema.Value
+			bodyRequired = operation.RequestBody.Value.Required
+		}
+	}
+	var validBody any
+	if bodySchema != nil {
+		validBody = exampleValue(bodySchema, 0)
+		valid.body, _ = json.Marshal(validBody)
+	}
+
+	var requests []fuzzRequest
+	for _, parameter := range parameters {
+		schema := parameterSchema(parameter)
+		switch parameter.In {
+		case openapi3.ParameterInPath:
+			for _, invalid := range []struct{ name, value string }{
+				{"wrong type", fmt.Sprint(wrongTypeValue(schema))},
+				{"oversized", strings.Repeat("x", 4096)},
+				{"path traversal in", "..%2F..%2F"},
+				{"special characters in", "%27%22%3C%3E%00"},
+			} {
+				if value, err := url.PathUnescape(invalid.value); err != nil || !parameterValueInvalid(schema, value) {
+					continue
+				}
+				request := valid
+				request.name = fmt.Sprintf("%s path parameter %s", invalid.name, parameter.Name)
+				request.path = expandPath(path, withValue(pathValues, parameter.Name, invalid.value))
+				requests = append(requests, request)
+			}
+		case openapi3.ParameterInQuery:
+			if value := fmt.Sprint(wrongTypeValue(schema)); parameterValueInvalid(schema, value) {
+				request := valid
+				request.name = "wrong type query parameter " + parameter.Name
+				request.query = cloneWith(query, parameter.Name, value)
+				requests = append(requests, request)
+			}
+			if parameter.Required {
+				request := valid
+				request.name = "missing query parameter " + parameter.Name
+				request.query = maps.Clone(query)
+				request.query.Del(parameter.Name)
+				requests = append(requests, request)
+			}
+		}
+	}
+
+	if bodySchema == nil {
+		return requests
+	}
+	addBody := func(name string, body []byte) {
+		if !bodyInvalid(bodySchema, body) {
+			return
+		}
+		request := valid
+		request.name = name
+		request.body = body
+		requests = append(requests, request)
+	}
+	addBody("invalid JSON body", []byte(`{"`))
+	if bodyRequired {
+		addBody("empty body", []byte{})
+	}
+	wrongBody, _ := json.Marshal(wrongTypeValue(bodySchema))
+	addBody("body of wrong type", wrongBody)
+	if object, ok := validBody.(map[string]any); ok {
+		for _, name := range bodySchema.Required {
+			body, _ := json.Marshal(withoutKey(object, name))
+			addBody("body missing required field "+name, body)
+		}
+		for _, name := range slices.Sorted(maps.Keys(bodySchema.Properties)) {
+			property := bodySchema.Properties[name]
+			if property.Value == nil {
+				continue
+			}
+			body, _ := json.Marshal(withKey(object, name, wrongTypeValue(property.Value)))
+			addBody("body field of wrong type "+name, body)
+		}
+	}
+	oversized := valid
+	oversized.name = "oversized body"
+	oversized.body = oversizedBody()
+	oversized.oversized = true
+	requests = append(requests, oversized)
+	return requests
+}
+
+// parameterValueInvalid reports whether the value of a path or query
+// parameter violates its schema. Values of array and object parameters are
+// never reported, since they are serialized in many styles.
+func parameterValueInvalid(schema *openapi3.Schema, raw string) bool {
+	if schema == nil {
+		return false
+	}
+	var value any
+	switch schemaType(schema) {
+	case openapi3.TypeString:
+		value = raw
+	case openapi3.TypeInteger, openapi3.TypeNumber:
+		number, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return true
+		}
+		value = number
+	case openapi3.TypeBoolean:
+		boolean, err := strconv.ParseBool(raw)
+		if err != nil {
+			return true
+		}
+		value = boolean
+	default:
+		return false
+	}
+	return schema.VisitJSON(value, openapi3.VisitAsRequest()) != nil
+}
+
+// bodyInvalid reports whether a JSON request body violates its schema.
+func bodyInvalid(schema *openapi3.Schema, body []byte) bool {
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return true
+	}
+	return schema.VisitJSON(value, openapi3.VisitAsRequest()) != nil
+}
+
+// exampleValue generates a value matching the schema, preferring the values
+// given by the specification.
+func exampleValue(schema *openapi3.Schema, depth int) any {
+	if schema == nil {
+		return "test"
+	}
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	case len(schema.OneOf) > 0 && schema.OneOf[0].Value != nil:
+		return exampleValue(schema.OneOf[0].Value, depth)
+	case len(schema.AnyOf) > 0 && schema.AnyOf[0].Value != nil:
+		return exampleValue(schema.AnyOf[0].Value, depth)
+	case len(schema.AllOf) > 0:
+		merged := make(map[string]any)
+		for _, part := range schema.AllOf {
+			if object, ok := exampleValue(part.Value, depth).(map[string]any); ok {
+				maps.Copy(merged, object)
+			}
+		}
+		return merged
+	}
+
+	switch schemaType(schema) {
+	case openapi3.TypeString:
+		switch schema.Format {
+		case "date-time":
+			return time.Now().UTC().Format(time.RFC3339)
+		case "date":
+			return time.Now().UTC().Format(time.DateOnly)
+		case "uuid":
+			return "00000000-0000-4000-8000-000000000000"
+		case "email":
+			return "test@example.com"
+		case "uri", "url":
+			return "http://example.com"
+		}
+		return "test" + strings.Repeat("x", int(max(schema.MinLength, 4)-4))
+	case openapi3.TypeInteger:
+		if schema.Min != nil {
+			return int64(math.Ceil(*schema.Min)) + 1
+		}
+		return 1
+	case openapi3.TypeNumber:
+		if schema.Min != nil {
+			return *schema.Min + 1
+		}
+		return 1.5
+	case openapi3.TypeBoolean:
+		return true
+	case openapi3.TypeArray:
+		items := make([]any, 0, schema.MinItems)
+		for range schema.MinItems {
+			if schema.Items == nil || depth >= maxExampleDepth {
+				items = append(items, "test")
+				continue
+			}
+			items = append(items, exampleValue(schema.Items.Value, depth+1))
+		}
+		return items
+	default:
+		object := make(map[string]any)
+		for name, property := range schema.Properties {
+			// Read-only properties are not part of requests.
+			if property.Value != nil && property.Value.ReadOnly {
+				continue
+			}
+			// Deeper down, only the required properties are generated.
+			if depth >= maxExampleDepth && !slices.Contains(schema.Required, name) {
+				continue
+			}
+			object[name] = exampleValue(property.Value, depth+1)
+		}
+		return object
+	}
+}
+
+// wrongTypeValue returns a value of another type than the schema allows.
+func wrongTypeValue(schema *openapi3.Schema) any {
+	switch schemaType(schema) {
+	case openapi3.TypeString:
+		return 12345
+	case openapi3.TypeInteger, openapi3.TypeNumber:
+		return "not-a-number"
+	case openapi3.TypeBoolean:
+		return "not-a-boolean"
+	case openapi3.TypeArray:
+		return map[string]any{"not": "an array"}
+	default:
+		return "not-an-object"
+	}
+}
+
+func schemaType(schema *openapi3.Schema) string {
+	if schema == nil {
+		return ""
+	}
+	if types := schema.Type.Slice(); len(types) > 0 {
+		return types[0]
+	}
+	if len(schema.Properties) > 0 {
+		return openapi3.TypeObject
+	}
+	return ""
+}
+
+func parameterSchema(parameter *openapi3.Parameter) *openapi3.Schema {
+	if parameter.Schema == nil {
+		return nil
+	}
+	return parameter.Schema.Value
+}
+
+// oversizedBody returns a huge body. The JSON is never terminated, so that no
+// schema can accept it.
+func oversizedBody() []byte {
+	return []byte(`{"oversized":"` + strings.Repeat("x", oversizedBodySize))
+}
+
+// expandPath fills in the path templates. The values are used as they are, so
+// that they can contain escaped characters.
+func expandPath(path string, values map[string]string) string {
+	for name, value := range values {
+		path = strings.ReplaceAll(path, "{"+name+"}", value)
+	}
+	return path
+}
+
+func withValue(values map[string]string, key, value string) map[string]string {
+	values = maps.Clone(values)
+	values[key] = value
+	return values
+}
+
+func cloneWith(values url.Values, key, value string) url.Values {
+	values = maps.Clone(values)
+	values.Set(key, value)
+	return values
+}
+
+func withKey(object map[string]any, key string, value any) map[string]any {
+	object = maps.Clone(object)
+	object[key] = value
+	return object
+}
+
+func withoutKey(object map[string]any, key string) map[string]any {
+	object = maps.Clone(object)
+	delete(object, key)
+	return object
+}
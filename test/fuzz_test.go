@@ -0,0 +1,186 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fuzzSpec = `{
+	"openapi": "3.0.3",
+	"info": {"title": "App", "version": "1.0.0"},
+	"paths": {
+		"/configs/{config-id}": {
+			"parameters": [{"name": "config-id", "in": "path", "required": true, "schema": {"type": "integer"}}],
+			"put": {
+				"parameters": [{"name": "dry-run", "in": "query", "schema": {"type": "boolean"}}],
+				"requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Config"}}}},
+				"responses": {"200": {"description": "OK"}}
+			},
+			"delete": {
+				"responses": {"204": {"description": "Deleted"}}
+			}
+		},
+		"/devices/{name}": {
+			"delete": {
+				"parameters": [
+					{"name": "name", "in": "path", "required": true, "schema": {"type": "string"}},
+					{"name": "reason", "in": "query", "required": true, "schema": {"type": "string"}}
+				],
+				"responses": {"204": {"description": "Deleted"}}
+			}
+		},
+		"/notes": {
+			"post": {
+				"requestBody": {"content": {"application/json": {"schema": {"type": "string"}}}},
+				"responses": {"201": {"description": "Created"}}
+			}
+		}
+	},
+	"components": {
+		"schemas": {
+			"Config": {
+				"type": "object",
+				"required": ["url", "interval", "id"],
+				"properties": {
+					"id": {"type": "integer", "readOnly": true},
+					"url": {"type": "string", "format": "uri"},
+					"interval": {"type": "integer", "minimum": 1},
+					"enable": {"type": "boolean"},
+					"tags": {"type": "array", "items": {"type": "string"}}
+				}
+			}
+		}
+	}
+}`
+
+func TestMalformedRequests(t *testing.T) {
+	spec, err := parseAPISpec([]byte(fuzzSpec))
+	require.NoError(t, err)
+
+	tests := []struct {
+		path   string
+		method string
+		want   []string
+	}{
+		{"/configs/{config-id}", "PUT", []string{
+			"wrong type path parameter config-id",
+			"oversized path parameter config-id",
+			"path traversal in path parameter config-id",
+			"special characters in path parameter config-id",
+			"wrong type query parameter dry-run",
+			"invalid JSON body",
+			"empty body",
+			"body of wrong type",
+			"body missing required field url",
+			"body missing required field interval",
+			"body field of wrong type enable",
+			"body field of wrong type id",
+			"body field of wrong type interval",
+			"body field of wrong type tags",
+			"body field of wrong type url",
+			"oversized body",
+		}},
+		{"/configs/{config-id}", "DELETE", []string{
+			"wrong type path parameter config-id",
+			"oversized path parameter config-id",
+			"path traversal in path parameter config-id",
+			"special characters in path parameter config-id",
+		}},
+		// Any value is a valid string, so only the missing parameter is invalid.
+		{"/devices/{name}", "DELETE", []string{
+			"missing query parameter reason",
+		}},
+		// The body is optional, and a number is not a string.
+		{"/notes", "POST", []string{
+			"invalid JSON body",
+			"body of wrong type",
+			"oversized body",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			pathItem := spec.Paths.Find(tt.path)
+			require.NotNil(t, pathItem)
+			requests := malformedRequests(tt.path, pathItem, pathItem.GetOperation(tt.method))
+
+			var names []string
+			for _, request := range requests {
+				names = append(names, request.name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
+
+func TestMalformedRequestsKeepValidParts(t *testing.T) {
+	spec, err := parseAPISpec([]byte(fuzzSpec))
+	require.NoError(t, err)
+	pathItem := spec.Paths.Find("/configs/{config-id}")
+	requests := malformedRequests("/configs/{config-id}", pathItem, pathItem.Put)
+
+	byName := make(map[string]fuzzRequest)
+	for _, request := range requests {
+		byName[request.name] = request
+	}
+	assert.Equal(t, "/configs/not-a-number", byName["wrong type path parameter config-id"].path)
+	assert.Equal(t, "/configs/..%2F..%2F", byName["path traversal in path parameter config-id"].path)
+	assert.Equal(t, "/configs/1", byName["body of wrong type"].path)
+	assert.Equal(t, "not-a-boolean", byName["wrong type query parameter dry-run"].query.Get("dry-run"))
+
+	var body map[string]any
+	require.NoError(t, json.Unmarshal(byName["body missing required field url"].body, &body))
+	assert.NotContains(t, body, "url")
+	assert.Equal(t, float64(2), body["interval"])
+
+	oversized := byName["oversized body"]
+	assert.True(t, oversized.oversized)
+	assert.GreaterOrEqual(t, len(oversized.body), oversizedBodySize)
+	assert.False(t, json.Valid(oversized.body))
+}
+
+func TestParameterValueInvalid(t *testing.T) {
+	minimum := 1.0
+	tests := []struct {
+		name   string
+		schema *openapi3.Schema
+		value  string
+		want   bool
+	}{
+		{"any string", openapi3.NewStringSchema(), "12345", false},
+		{"string too long", openapi3.NewStringSchema().WithMaxLength(3), "abcd", true},
+		{"string not in enum", openapi3.NewStringSchema().WithEnum("on", "off"), "dim", true},
+		{"string in enum", openapi3.NewStringSchema().WithEnum("on", "off"), "on", false},
+		{"integer", openapi3.NewIntegerSchema(), "42", false},
+		{"integer with fraction", openapi3.NewIntegerSchema(), "1.5", true},
+		{"integer below minimum", &openapi3.Schema{Type: &openapi3.Types{openapi3.TypeInteger}, Min: &minimum}, "0", true},
+		{"not a number", openapi3.NewFloat64Schema(), "not-a-number", true},
+		{"boolean", openapi3.NewBoolSchema(), "true", false},
+		{"not a boolean", openapi3.NewBoolSchema(), "not-a-boolean", true},
+		{"array", openapi3.NewArraySchema(), "not an array", false},
+		{"no schema", nil, "x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, parameterValueInvalid(tt.schema, tt.value))
+		})
+	}
+}
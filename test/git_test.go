@@ -0,0 +1,189 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	mainCommit   = "0123456789abcdef0123456789abcdef01234567"
+	branchCommit = "89abcdef0123456789abcdef0123456789abcdef"
+	tagCommit    = "fedcba9876543210fedcba9876543210fedcba98"
+)
+
+const packedRefs = `# pack-refs with: peeled fully-peeled sorted
+` + branchCommit + ` refs/heads/feature
+` + tagCommit + ` refs/tags/v1.0.0
+^` + mainCommit + `
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestGitHead(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		dir     string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "detached HEAD",
+			files: map[string]string{".git/HEAD": mainCommit + "\n"},
+			want:  mainCommit,
+		},
+		{
+			name: "loose ref",
+			files: map[string]string{
+				".git/HEAD":            "ref: refs/heads/main\n",
+				".git/refs/heads/main": mainCommit + "\n",
+				".git/packed-refs":     packedRefs,
+			},
+			want: mainCommit,
+		},
+		{
+			name: "packed ref",
+			files: map[string]string{
+				".git/HEAD":        "ref: refs/heads/feature\n",
+				".git/packed-refs": packedRefs,
+			},
+			want: branchCommit,
+		},
+		{
+			name: "worktree",
+			files: map[string]string{
+				"repo/.git/packed-refs":            packedRefs,
+				"repo/.git/worktrees/wt/HEAD":      "ref: refs/heads/feature\n",
+				"repo/.git/worktrees/wt/commondir": "../..\n",
+				"wt/.git":                          "gitdir: ../repo/.git/worktrees/wt\n",
+			},
+			dir:  "wt",
+			want: branchCommit,
+		},
+		{
+			name: "worktree with loose ref in common dir",
+			files: map[string]string{
+				"repo/.git/refs/heads/feature":     mainCommit + "\n",
+				"repo/.git/packed-refs":            packedRefs,
+				"repo/.git/worktrees/wt/HEAD":      "ref: refs/heads/feature\n",
+				"repo/.git/worktrees/wt/commondir": "../..\n",
+				"wt/.git":                          "gitdir: ../repo/.git/worktrees/wt\n",
+			},
+			dir:  "wt",
+			want: mainCommit,
+		},
+		{
+			name: "missing ref",
+			files: map[string]string{
+				".git/HEAD":        "ref: refs/heads/main\n",
+				".git/packed-refs": packedRefs,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "missing packed-refs",
+			files:   map[string]string{".git/HEAD": "ref: refs/heads/main\n"},
+			wantErr: true,
+		},
+		{
+			name:    "missing HEAD",
+			files:   map[string]string{".git/config": ""},
+			wantErr: true,
+		},
+		{
+			name:    "invalid .git file",
+			files:   map[string]string{".git": "not a git file\n"},
+			wantErr: true,
+		},
+		{
+			name:    "no repository",
+			files:   map[string]string{"README.md": ""},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeFiles(t, root, tt.files)
+
+			head, err := gitHead(filepath.Join(root, tt.dir))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, head)
+		})
+	}
+}
+
+func TestGitHeadAbsoluteGitDir(t *testing.T) {
+	root := t.TempDir()
+	gitDir := filepath.Join(root, "modules", "app")
+	writeFiles(t, root, map[string]string{
+		"modules/app/HEAD": mainCommit + "\n",
+		"app/.git":         "gitdir: " + gitDir + "\n",
+	})
+
+	head, err := gitHead(filepath.Join(root, "app"))
+	require.NoError(t, err)
+	assert.Equal(t, mainCommit, head)
+}
+
+func TestPackedRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"refs/heads/feature", branchCommit, false},
+		{"refs/tags/v1.0.0", tagCommit, false},
+		{"refs/heads/main", "", true},
+		{"pack-refs with: peeled fully-peeled sorted", "", true},
+		{mainCommit, "", true},
+	}
+	gitDir := t.TempDir()
+	writeFiles(t, gitDir, map[string]string{"packed-refs": packedRefs})
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			commit, err := packedRef(gitDir, tt.ref)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, commit)
+		})
+	}
+}
+
+func TestPackedRefMissingFile(t *testing.T) {
+	_, err := packedRef(t.TempDir(), "refs/heads/main")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
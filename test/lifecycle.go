@@ -0,0 +1,74 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// AppShutsDownGracefully stops the app with SIGTERM and checks that it exits
+// cleanly within the shutdown budget. The app is started again afterwards, so
+// that tests running later still have an app to talk to.
+func AppShutsDownGracefully(t *testing.T) {
+	h := app.Default()
+	if h == nil {
+		t.Skip("App is not started by the harness")
+	}
+	ctx := context.Background()
+
+	assert.NoError(t, h.Stop(ctx), "Stopping app")
+	result := h.LastStop()
+	assert.False(t, result.Killed, "App should stop without being killed")
+	assert.Equal(t, 0, result.ExitCode, "App should exit with code 0")
+	assert.LessOrEqualf(t, result.Duration, h.ShutdownBudget(), "App should stop within %s", h.ShutdownBudget())
+	assert.Empty(t, result.LogErrors, "App shouldn't log errors while stopping")
+
+	assert.NoError(t, h.Start(ctx), "Restarting app after shutdown")
+}
+
+// ResetScriptIsComplete stops the app and checks that reset.sql can run twice
+// and removes everything the app created. The app is started again afterwards.
+func ResetScriptIsComplete(t *testing.T) {
+	h := app.Default()
+	if h == nil {
+		t.Skip("App is not started by the harness")
+	}
+	ctx := context.Background()
+
+	require.NoError(t, h.Stop(ctx), "Stopping app")
+	assert.NoError(t, h.VerifyReset(ctx), "Reset script should be idempotent and complete")
+
+	assert.NoError(t, h.Start(ctx), "Restarting app after reset")
+}
+
+// restartApp stops the app and starts it again, which resets the database, so
+// that changes made by a check do not leak into the tests running later.
+func restartApp(t *testing.T) {
+	h := app.Default()
+	if h == nil {
+		return
+	}
+	ctx := context.Background()
+
+	assert.NoError(t, h.Stop(ctx), "Stopping app")
+	assert.Empty(t, h.LastStop().LogErrors, "App shouldn't log errors while stopping")
+	assert.NoError(t, h.Start(ctx), "Restarting app")
+}
@@ -75,8 +75,6 @@ func IconFileIsValid(t *testing.T) {
 }
 
 func CanAddAppToStore(t *testing.T) {
-	t.Parallel()
-
 	metadata, metadataData, err := eapp.GetMetadata()
 	require.NoError(t, err, "Getting metadata successful")
 
@@ -98,8 +96,6 @@ func CanAddAppToStore(t *testing.T) {
 }
 
 func AppIsInitialized(t *testing.T) {
-	t.Parallel()
-
 	metadata, _, err := eapp.GetMetadata()
 	require.NoError(t, err, "Getting metadata successful")
 
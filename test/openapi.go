@@ -0,0 +1,185 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/eliona-smart-building-assistant/app-integration-tests/app"
+	eapp "github.com/eliona-smart-building-assistant/go-eliona/app"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// getAPISpec fetches the API specification served by the app and parses it as
+// an OpenAPI 3.x document.
+func getAPISpec(t *testing.T) *openapi3.T {
+	spec, err := parseAPISpec(getAPISpecData(t))
+	require.NoError(t, err, "API specification should be a valid OpenAPI 3.x document")
+	return spec
+}
+
+// getAPISpecData fetches the API specification served by the app.
+func getAPISpecData(t *testing.T) []byte {
+	metadata := getMetadata(t)
+	resp := getUrl(t, fmt.Sprintf("%s/%s/%s", app.BaseURL(), metadata.ApiUrl, metadata.ApiSpecificationPath))
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "Reading response body")
+	require.True(t, json.Valid(data), "API specification should be JSON")
+	return data
+}
+
+// parseAPISpec parses and validates an OpenAPI 3.x document in JSON or YAML.
+// All references have to be resolvable within the document. The errors name
+// the path to the problem, e.g. "invalid paths: invalid path /configs:
+// invalid operation GET: ...".
+func parseAPISpec(data []byte) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	spec, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("loading document: %w", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		return nil, fmt.Errorf("invalid openapi: version %q is not 3.x", spec.OpenAPI)
+	}
+	if err := spec.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("validating document: %w", err)
+	}
+	return spec, nil
+}
+
+// APISpecMatchesFile checks that the app serves the API specification of its
+// repository, not a stale copy of it. Formatting and the choice of JSON or
+// YAML do not matter.
+func APISpecMatchesFile(t *testing.T) {
+	path, err := findAPISpecFile(getMetadata(t))
+	require.NoError(t, err, "Finding API specification file")
+	fileData, err := os.ReadFile(path)
+	require.NoErrorf(t, err, "Reading %s", path)
+
+	file, err := normalizeDocument(fileData)
+	require.NoErrorf(t, err, "Parsing %s", path)
+	served, err := normalizeDocument(getAPISpecData(t))
+	require.NoError(t, err, "Parsing served API specification")
+
+	differences := documentDifferences("", file, served)
+	assert.Emptyf(t, differences, "Served API specification should match %s", path)
+}
+
+// findAPISpecFile returns the API specification in the app directory. It is
+// looked for at the path it is served from, under the name it is served with
+// in any format, and as openapi.yaml, openapi.yml or openapi.json, in the app
+// directory and in the api directory.
+func findAPISpecFile(metadata eapp.Metadata) (string, error) {
+	servedPath := strings.TrimPrefix(metadata.ApiSpecificationPath, "/")
+	servedName := strings.TrimSuffix(filepath.Base(servedPath), filepath.Ext(servedPath))
+	names := []string{servedName}
+	if servedName != "openapi" {
+		names = append(names, "openapi")
+	}
+	candidates := []string{servedPath}
+	for _, name := range names {
+		for _, dir := range []string{".", "api"} {
+			for _, ext := range []string{".yaml", ".yml", ".json"} {
+				candidates = append(candidates, filepath.Join(dir, name+ext))
+			}
+		}
+	}
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("no API specification file found, looked for %s", strings.Join(candidates, ", "))
+}
+
+// normalizeDocument parses a JSON or YAML document into the values the JSON
+// decoder produces, so that documents in both formats can be compared.
+func normalizeDocument(data []byte) (any, error) {
+	var document any
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	normalized, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	document = nil
+	if err := json.Unmarshal(normalized, &document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// documentDifferences returns the JSON pointers to the values differing
+// between two normalized documents.
+func documentDifferences(pointer string, want, got any) []string {
+	switch want := want.(type) {
+	case map[string]any:
+		got, ok := got.(map[string]any)
+		if !ok {
+			return []string{pointer + ": differs"}
+		}
+		var differences []string
+		for _, key := range slices.Sorted(maps.Keys(want)) {
+			child := pointer + "/" + escapePointer(key)
+			if _, present := got[key]; !present {
+				differences = append(differences, child+": missing")
+				continue
+			}
+			differences = append(differences, documentDifferences(child, want[key], got[key])...)
+		}
+		for _, key := range slices.Sorted(maps.Keys(got)) {
+			if _, present := want[key]; !present {
+				differences = append(differences, pointer+"/"+escapePointer(key)+": unexpected")
+			}
+		}
+		return differences
+	case []any:
+		got, ok := got.([]any)
+		if !ok || len(got) != len(want) {
+			return []string{pointer + ": differs"}
+		}
+		var differences []string
+		for i := range want {
+			differences = append(differences, documentDifferences(fmt.Sprintf("%s/%d", pointer, i), want[i], got[i])...)
+		}
+		return differences
+	default:
+		if want != got {
+			return []string{pointer + ": differs"}
+		}
+		return nil
+	}
+}
+
+// escapePointer escapes a key for use in a JSON pointer.
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
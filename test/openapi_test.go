@@ -0,0 +1,169 @@
+//  This file is part of the eliona project.
+//  Copyright © 2022 LEICOM iTEC AG. All Rights Reserved.
+//  ______ _ _
+// |  ____| (_)
+// | |__  | |_  ___  _ __   __ _
+// |  __| | | |/ _ \| '_ \ / _` |
+// | |____| | | (_) | | | | (_| |
+// |______|_|_|\___/|_| |_|\__,_|
+//
+//  THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING
+//  BUT NOT LIMITED  TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND
+//  NON INFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM,
+//  DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+//  OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDocument(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    any
+		wantErr bool
+	}{
+		{
+			name: "json",
+			data: `{"openapi":"3.0.3","info":{"title":"App","version":"1.0"}}`,
+			want: map[string]any{"openapi": "3.0.3", "info": map[string]any{"title": "App", "version": "1.0"}},
+		},
+		{
+			name: "yaml",
+			data: "openapi: 3.0.3\ninfo:\n  title: App\n  version: '1.0'\n",
+			want: map[string]any{"openapi": "3.0.3", "info": map[string]any{"title": "App", "version": "1.0"}},
+		},
+		{
+			name: "yaml numbers and booleans",
+			data: "maximum: 10\nminimum: 0.5\nnullable: true\nenum: [a, 1]\n",
+			want: map[string]any{"maximum": float64(10), "minimum": 0.5, "nullable": true, "enum": []any{"a", float64(1)}},
+		},
+		{
+			name: "yaml status code keys",
+			data: "responses:\n  200:\n    description: OK\n  default:\n    description: Error\n",
+			want: map[string]any{"responses": map[string]any{
+				"200":     map[string]any{"description": "OK"},
+				"default": map[string]any{"description": "Error"},
+			}},
+		},
+		{
+			name: "json null",
+			data: `{"example":null}`,
+			want: map[string]any{"example": nil},
+		},
+		{
+			name:    "invalid",
+			data:    "openapi: [3.0.3",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document, err := normalizeDocument([]byte(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, document)
+		})
+	}
+}
+
+func TestNormalizedDocumentsInBothFormatsAreEqual(t *testing.T) {
+	fromJSON, err := normalizeDocument([]byte(`{"paths":{"/version":{"get":{"responses":{"200":{"description":"OK"}}}}}}`))
+	require.NoError(t, err)
+	fromYAML, err := normalizeDocument([]byte("paths:\n  /version:\n    get:\n      responses:\n        200:\n          description: OK\n"))
+	require.NoError(t, err)
+	assert.Empty(t, documentDifferences("", fromJSON, fromYAML))
+}
+
+func TestDocumentDifferences(t *testing.T) {
+	tests := []struct {
+		name string
+		want any
+		got  any
+		diff []string
+	}{
+		{
+			name: "equal",
+			want: map[string]any{"a": []any{"x", float64(1)}, "b": nil},
+			got:  map[string]any{"a": []any{"x", float64(1)}, "b": nil},
+		},
+		{
+			name: "changed value",
+			want: map[string]any{"info": map[string]any{"version": "1.0"}},
+			got:  map[string]any{"info": map[string]any{"version": "1.1"}},
+			diff: []string{"/info/version: differs"},
+		},
+		{
+			name: "missing and unexpected keys",
+			want: map[string]any{"a": "1", "b": "2"},
+			got:  map[string]any{"b": "2", "c": "3"},
+			diff: []string{"/a: missing", "/c: unexpected"},
+		},
+		{
+			name: "sorted keys",
+			want: map[string]any{"z": "1", "a": "1", "m": "1"},
+			got:  map[string]any{"z": "2", "a": "2", "m": "2"},
+			diff: []string{"/a: differs", "/m: differs", "/z: differs"},
+		},
+		{
+			name: "escaped keys",
+			want: map[string]any{"paths": map[string]any{"/configs/{id}": "a", "x~y": "b"}},
+			got:  map[string]any{"paths": map[string]any{"/configs/{id}": "c", "x~y": "d"}},
+			diff: []string{"/paths/~1configs~1{id}: differs", "/paths/x~0y: differs"},
+		},
+		{
+			name: "array element",
+			want: map[string]any{"tags": []any{"a", "b"}},
+			got:  map[string]any{"tags": []any{"a", "c"}},
+			diff: []string{"/tags/1: differs"},
+		},
+		{
+			name: "array length",
+			want: map[string]any{"tags": []any{"a", "b"}},
+			got:  map[string]any{"tags": []any{"a"}},
+			diff: []string{"/tags: differs"},
+		},
+		{
+			name: "different types",
+			want: map[string]any{"a": map[string]any{}, "b": []any{}, "c": "1"},
+			got:  map[string]any{"a": []any{}, "b": map[string]any{}, "c": float64(1)},
+			diff: []string{"/a: differs", "/b: differs", "/c: differs"},
+		},
+		{
+			name: "root",
+			want: "a",
+			got:  "b",
+			diff: []string{": differs"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.diff, documentDifferences("", tt.want, tt.got))
+		})
+	}
+}
+
+func TestEscapePointer(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"info", "info"},
+		{"/configs", "~1configs"},
+		{"a~b", "a~0b"},
+		{"~/", "~0~1"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, escapePointer(tt.key), "key %q", tt.key)
+	}
+}